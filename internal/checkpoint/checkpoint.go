@@ -0,0 +1,54 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const backfillTimeFormat = "20060102T150405Z"
+
+// ErrNotFound is returned when a checkpoint has not been recorded yet.
+var ErrNotFound = errors.New("checkpoint not found")
+
+// Checkpoint records the last event which was exported from a log stream.
+type Checkpoint struct {
+	// Timestamp of the last exported event in milliseconds. Used as the start time of the next run.
+	Timestamp int64 `json:"timestamp"`
+	// EventID of the last exported event. Events sharing the timestamp up to and including this event
+	// are skipped by the next run.
+	EventID string `json:"event_id,omitempty"`
+	// NextToken is set when the last run stopped before the end of its window eg. to avoid a timeout.
+	// The next run continues paging from it using the same StartTime and EndTime.
+	NextToken string `json:"next_token,omitempty"`
+	StartTime int64  `json:"start_time,omitempty"`
+	EndTime   int64  `json:"end_time,omitempty"`
+}
+
+// Store persists checkpoints between runs.
+type Store interface {
+	// Get returns the checkpoint for a key or ErrNotFound.
+	Get(ctx context.Context, key string) (Checkpoint, error)
+	// Put records the checkpoint for a key.
+	Put(ctx context.Context, key string, checkpoint Checkpoint) error
+}
+
+// Key returns the checkpoint key of a job for a log group and stream. Jobs on the same log group keep separate
+// checkpoints. Jobs named after their log group, which is the default, keep the key of the log group and stream
+// so that checkpoints recorded before jobs were part of the key carry over.
+func Key(job, group, stream string) string {
+	key := strings.TrimPrefix(group, "/") + "/" + stream
+
+	if job == "" || job == group {
+		return key
+	}
+
+	return "jobs/" + strings.TrimPrefix(job, "/") + "/" + key
+}
+
+// BackfillKey returns the key used to record the progress of a job backfilling the [from, to) range.
+func BackfillKey(job string, from, to time.Time) string {
+	return fmt.Sprintf("backfill/%s/%s-%s", strings.TrimPrefix(job, "/"), from.UTC().Format(backfillTimeFormat), to.UTC().Format(backfillTimeFormat))
+}
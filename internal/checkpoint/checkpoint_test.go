@@ -0,0 +1,93 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockS3 struct {
+	objects map[string][]byte
+	// Denied reports missing keys as S3 does to callers without s3:ListBucket.
+	denied bool
+}
+
+func (m *mockS3) GetObject(_ context.Context, params *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[*params.Bucket+"/"+*params.Key]
+	if !ok && m.denied {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	}
+
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (m *mockS3) PutObject(_ context.Context, params *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.objects[*params.Bucket+"/"+*params.Key] = data
+
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "skpr/test/things/ecs/app/123", Key("/skpr/test/things", "/skpr/test/things", "ecs/app/123"))
+	assert.Equal(t, "skpr/test/things/ecs/app/123", Key("", "/skpr/test/things", "ecs/app/123"))
+	assert.Equal(t, "jobs/sentinel/skpr/test/things/ecs/app/123", Key("sentinel", "/skpr/test/things", "ecs/app/123"))
+}
+
+func TestBackfillKey(t *testing.T) {
+	from := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "backfill/skpr/test/things/20260701T000000Z-20261001T000000Z", BackfillKey("/skpr/test/things", from, to))
+}
+
+func TestStores(t *testing.T) {
+	client := &mockS3{objects: make(map[string][]byte)}
+
+	stores := map[string]Store{
+		"file": NewFileStore(t.TempDir()),
+		"s3":   NewS3Store(client, "skpr-test", "/checkpoints"),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			key := Key("/skpr/test/things", "/skpr/test/things", "ecs/app/123")
+
+			_, err := store.Get(ctx, key)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			want := Checkpoint{Timestamp: 1697414400000, EventID: "abc"}
+
+			assert.NoError(t, store.Put(ctx, key, want))
+
+			got, err := store.Get(ctx, key)
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+
+	assert.Contains(t, client.objects, "skpr-test/checkpoints/skpr/test/things/ecs/app/123.json")
+}
+
+func TestS3StoreAccessDenied(t *testing.T) {
+	store := NewS3Store(&mockS3{objects: make(map[string][]byte), denied: true}, "skpr-test", "/checkpoints")
+
+	_, err := store.Get(context.Background(), Key("/skpr/test/things", "/skpr/test/things", "fpm"))
+	assert.NotErrorIs(t, err, ErrNotFound)
+	assert.ErrorContains(t, err, "s3:ListBucket is required")
+}
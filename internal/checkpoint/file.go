@@ -0,0 +1,64 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileStore persists checkpoints as JSON files on the local filesystem.
+type FileStore struct {
+	Directory string
+}
+
+// NewFileStore returns a store which writes checkpoints to a directory.
+func NewFileStore(directory string) *FileStore {
+	return &FileStore{Directory: directory}
+}
+
+// Get returns the checkpoint for a key.
+func (s *FileStore) Get(_ context.Context, key string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, ErrNotFound
+	}
+
+	if err != nil {
+		return checkpoint, fmt.Errorf("failed to read checkpoint, %w", err)
+	}
+
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("failed to unmarshal checkpoint, %w", err)
+	}
+
+	return checkpoint, nil
+}
+
+// Put records the checkpoint for a key.
+func (s *FileStore) Put(_ context.Context, key string, checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint, %w", err)
+	}
+
+	path := s.path(key)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory, %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint, %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.Directory, filepath.FromSlash(key)+".json")
+}
@@ -0,0 +1,93 @@
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// S3API is the subset of the S3 client used by the S3 store.
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// S3Store persists checkpoints as JSON objects in an S3 bucket.
+type S3Store struct {
+	Client S3API
+	Bucket string
+	Prefix string
+}
+
+// NewS3Store returns a store which writes checkpoints to a bucket.
+func NewS3Store(client S3API, bucket, prefix string) *S3Store {
+	return &S3Store{
+		Client: client,
+		Bucket: bucket,
+		Prefix: prefix,
+	}
+}
+
+// Get returns the checkpoint for a key.
+func (s *S3Store) Get(ctx context.Context, key string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+
+	resp, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return checkpoint, ErrNotFound
+		}
+
+		// S3 hides whether a key exists from callers which can't list the bucket.
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied" {
+			return checkpoint, fmt.Errorf("failed to get checkpoint, s3:ListBucket is required for missing checkpoints to be found, %w", err)
+		}
+
+		return checkpoint, fmt.Errorf("failed to get checkpoint, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("failed to unmarshal checkpoint, %w", err)
+	}
+
+	return checkpoint, nil
+}
+
+// Put records the checkpoint for a key.
+func (s *S3Store) Put(ctx context.Context, key string, checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint, %w", err)
+	}
+
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.key(key)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put checkpoint, %w", err)
+	}
+
+	return nil
+}
+
+func (s *S3Store) key(key string) string {
+	return strings.TrimPrefix(path.Join(s.Prefix, key+".json"), "/")
+}
@@ -0,0 +1,388 @@
+package cloudwatchtest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+)
+
+// ErrThrottling is returned by the fake when it is throttling requests.
+var ErrThrottling = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+
+// Fake is an in-memory stand-in for CloudWatch Logs. Pages follow the behaviour of the service: GetLogEvents
+// returns the token it was given once the end of the stream is reached, and pages can be empty before then.
+type Fake struct {
+	mu sync.Mutex
+	// Events of each stream by group, in the order of their timestamps.
+	groups map[string]map[string][]types.OutputLogEvent
+	// PageSize is the number of events or streams in each page. Defaults to 10000 events and 50 streams.
+	PageSize int
+	// EmptyPages returned by GetLogEvents before each page which has events.
+	EmptyPages int
+	// Errors returned by the next requests, in order, before any are served eg. ErrThrottling.
+	Errors []error
+	// Calls counts the requests made to each operation by name eg. "GetLogEvents".
+	Calls map[string]int
+}
+
+// New returns an empty fake.
+func New() *Fake {
+	return &Fake{
+		groups: make(map[string]map[string][]types.OutputLogEvent),
+		Calls:  make(map[string]int),
+	}
+}
+
+// Event returns a log event with a message at a time. It is ingested at the same time.
+func Event(timestamp time.Time, message string) types.OutputLogEvent {
+	return types.OutputLogEvent{
+		Timestamp:     aws.Int64(timestamp.UnixMilli()),
+		IngestionTime: aws.Int64(timestamp.UnixMilli()),
+		Message:       aws.String(message),
+	}
+}
+
+// Put adds events to a stream, creating it when needed.
+func (f *Fake) Put(group, stream string, events ...types.OutputLogEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.groups[group] == nil {
+		f.groups[group] = make(map[string][]types.OutputLogEvent)
+	}
+
+	list := append(f.groups[group][stream], events...)
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return aws.ToInt64(list[i].Timestamp) < aws.ToInt64(list[j].Timestamp)
+	})
+
+	f.groups[group][stream] = list
+}
+
+// Throttle the next n requests.
+func (f *Fake) Throttle(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		f.Errors = append(f.Errors, ErrThrottling)
+	}
+}
+
+// Records a call and returns the next queued error, if any.
+func (f *Fake) call(operation string) error {
+	f.Calls[operation]++
+
+	if len(f.Errors) == 0 {
+		return nil
+	}
+
+	err := f.Errors[0]
+	f.Errors = f.Errors[1:]
+
+	return err
+}
+
+// GetLogEvents returns a page of the events in the [StartTime, EndTime) window of a stream, oldest first.
+func (f *Fake) GetLogEvents(_ context.Context, params *cloudwatchlogs.GetLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("GetLogEvents"); err != nil {
+		return nil, err
+	}
+
+	events, ok := f.groups[aws.ToString(params.LogGroupName)][aws.ToString(params.LogStreamName)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("The specified log stream does not exist.")}
+	}
+
+	var window []types.OutputLogEvent
+
+	for _, event := range events {
+		timestamp := aws.ToInt64(event.Timestamp)
+
+		if params.StartTime != nil && timestamp < *params.StartTime {
+			continue
+		}
+
+		if params.EndTime != nil && timestamp >= *params.EndTime {
+			continue
+		}
+
+		window = append(window, event)
+	}
+
+	var offset, empty int
+
+	if params.NextToken != nil {
+		if _, err := fmt.Sscanf(*params.NextToken, "f/%d/%d", &offset, &empty); err != nil {
+			return nil, &types.InvalidParameterException{Message: aws.String("The specified nextToken is invalid.")}
+		}
+	}
+
+	// The end of the stream returns the token which was passed in.
+	if offset >= len(window) {
+		return &cloudwatchlogs.GetLogEventsOutput{
+			NextForwardToken: aws.String(token(offset, empty)),
+		}, nil
+	}
+
+	if empty < f.EmptyPages {
+		return &cloudwatchlogs.GetLogEventsOutput{
+			NextForwardToken: aws.String(token(offset, empty+1)),
+		}, nil
+	}
+
+	size := f.PageSize
+	if size <= 0 {
+		size = 10000
+	}
+
+	end := min(offset+size, len(window))
+
+	return &cloudwatchlogs.GetLogEventsOutput{
+		Events:           window[offset:end],
+		NextForwardToken: aws.String(token(end, 0)),
+	}, nil
+}
+
+// FilterLogEvents returns a page of the events in the [StartTime, EndTime) window of the named streams which match the
+// filter pattern. Only term patterns are supported eg. "ERROR", "?ERROR ?WARN", "\"status 500\"" or "ERROR -healthz".
+func (f *Fake) FilterLogEvents(_ context.Context, params *cloudwatchlogs.FilterLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("FilterLogEvents"); err != nil {
+		return nil, err
+	}
+
+	group, ok := f.groups[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("The specified log group does not exist.")}
+	}
+
+	terms, err := parsePattern(aws.ToString(params.FilterPattern))
+	if err != nil {
+		return nil, &types.InvalidParameterException{Message: aws.String(err.Error())}
+	}
+
+	var matched []types.FilteredLogEvent
+
+	for _, name := range params.LogStreamNames {
+		for i, event := range group[name] {
+			timestamp := aws.ToInt64(event.Timestamp)
+
+			if params.StartTime != nil && timestamp < *params.StartTime {
+				continue
+			}
+
+			if params.EndTime != nil && timestamp >= *params.EndTime {
+				continue
+			}
+
+			if !terms.match(aws.ToString(event.Message)) {
+				continue
+			}
+
+			matched = append(matched, types.FilteredLogEvent{
+				EventId:       aws.String(fmt.Sprintf("%s/%d", name, i)),
+				LogStreamName: aws.String(name),
+				Timestamp:     event.Timestamp,
+				IngestionTime: event.IngestionTime,
+				Message:       event.Message,
+			})
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return aws.ToInt64(matched[i].Timestamp) < aws.ToInt64(matched[j].Timestamp)
+	})
+
+	var offset, empty int
+
+	if params.NextToken != nil {
+		if _, err := fmt.Sscanf(*params.NextToken, "l/%d/%d", &offset, &empty); err != nil {
+			return nil, &types.InvalidParameterException{Message: aws.String("The specified nextToken is invalid.")}
+		}
+	}
+
+	// The search can return empty pages while it is in progress.
+	if offset < len(matched) && empty < f.EmptyPages {
+		return &cloudwatchlogs.FilterLogEventsOutput{
+			NextToken: aws.String(fmt.Sprintf("l/%d/%d", offset, empty+1)),
+		}, nil
+	}
+
+	size := f.PageSize
+	if size <= 0 {
+		size = 10000
+	}
+
+	end := min(offset+size, len(matched))
+
+	output := &cloudwatchlogs.FilterLogEventsOutput{
+		Events: matched[min(offset, end):end],
+	}
+
+	// The token is left out once every matching event has been returned.
+	if end < len(matched) {
+		output.NextToken = aws.String(fmt.Sprintf("l/%d/0", end))
+	}
+
+	return output, nil
+}
+
+// Terms of a filter pattern.
+type terms struct {
+	all, any, none []string
+}
+
+// Parses a term pattern. Terms prefixed with "?" match when any of them appear, and "-" when they don't.
+func parsePattern(pattern string) (terms, error) {
+	var t terms
+
+	pattern = strings.TrimSpace(pattern)
+
+	if strings.HasPrefix(pattern, "{") || strings.HasPrefix(pattern, "[") {
+		return t, fmt.Errorf("JSON and space-delimited filter patterns are not supported by the fake")
+	}
+
+	for len(pattern) > 0 {
+		var prefix string
+
+		if pattern[0] == '?' || pattern[0] == '-' {
+			prefix, pattern = pattern[:1], pattern[1:]
+		}
+
+		var term string
+
+		if strings.HasPrefix(pattern, `"`) {
+			end := strings.Index(pattern[1:], `"`)
+			if end < 0 {
+				return t, fmt.Errorf("unclosed quote in filter pattern")
+			}
+
+			term, pattern = pattern[1:end+1], pattern[end+2:]
+		} else {
+			term, pattern, _ = strings.Cut(pattern, " ")
+		}
+
+		switch prefix {
+		case "?":
+			t.any = append(t.any, term)
+		case "-":
+			t.none = append(t.none, term)
+		default:
+			t.all = append(t.all, term)
+		}
+
+		pattern = strings.TrimSpace(pattern)
+	}
+
+	return t, nil
+}
+
+// Reports whether a message matches the terms.
+func (t terms) match(message string) bool {
+	for _, term := range t.all {
+		if !strings.Contains(message, term) {
+			return false
+		}
+	}
+
+	for _, term := range t.none {
+		if strings.Contains(message, term) {
+			return false
+		}
+	}
+
+	if len(t.any) == 0 {
+		return true
+	}
+
+	for _, term := range t.any {
+		if strings.Contains(message, term) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the forward token of a position within a stream.
+func token(offset, empty int) string {
+	return fmt.Sprintf("f/%d/%d", offset, empty)
+}
+
+// DescribeLogStreams returns a page of the streams in a group, ordered by name.
+func (f *Fake) DescribeLogStreams(_ context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call("DescribeLogStreams"); err != nil {
+		return nil, err
+	}
+
+	group, ok := f.groups[aws.ToString(params.LogGroupName)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("The specified log group does not exist.")}
+	}
+
+	var names []string
+
+	for name := range group {
+		if strings.HasPrefix(name, aws.ToString(params.LogStreamNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	var offset int
+
+	if params.NextToken != nil {
+		if _, err := fmt.Sscanf(*params.NextToken, "d/%d", &offset); err != nil {
+			return nil, &types.InvalidParameterException{Message: aws.String("The specified nextToken is invalid.")}
+		}
+	}
+
+	size := f.PageSize
+	if size <= 0 {
+		size = 50
+	}
+
+	end := min(offset+size, len(names))
+
+	output := &cloudwatchlogs.DescribeLogStreamsOutput{}
+
+	for _, name := range names[min(offset, end):end] {
+		stream := types.LogStream{
+			LogStreamName: aws.String(name),
+		}
+
+		// Streams which have never received an event don't have any timestamps.
+		if events := group[name]; len(events) > 0 {
+			stream.FirstEventTimestamp = events[0].Timestamp
+			stream.LastEventTimestamp = events[len(events)-1].Timestamp
+			stream.LastIngestionTime = events[len(events)-1].IngestionTime
+		}
+
+		output.LogStreams = append(output.LogStreams, stream)
+	}
+
+	if end < len(names) {
+		output.NextToken = aws.String(fmt.Sprintf("d/%d", end))
+	}
+
+	return output, nil
+}
@@ -0,0 +1,176 @@
+package cloudwatchtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLogEvents(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	fake := New()
+	fake.PageSize = 2
+	fake.EmptyPages = 1
+	fake.Put("/skpr/test/things", "fpm",
+		Event(start.Add(2*time.Second), "third"),
+		Event(start, "first"),
+		Event(start.Add(time.Second), "second"),
+	)
+
+	input := &cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  aws.String("/skpr/test/things"),
+		LogStreamName: aws.String("fpm"),
+	}
+
+	var (
+		pages    []int
+		messages []string
+	)
+
+	for {
+		output, err := fake.GetLogEvents(context.Background(), input)
+		assert.NoError(t, err)
+
+		pages = append(pages, len(output.Events))
+
+		for _, event := range output.Events {
+			messages = append(messages, aws.ToString(event.Message))
+		}
+
+		// The end of the stream returns the same token.
+		if input.NextToken != nil && *output.NextForwardToken == *input.NextToken {
+			break
+		}
+
+		input.NextToken = output.NextForwardToken
+	}
+
+	assert.Equal(t, []int{0, 2, 0, 1, 0}, pages)
+	assert.Equal(t, []string{"first", "second", "third"}, messages)
+}
+
+func TestGetLogEventsErrors(t *testing.T) {
+	fake := New()
+	fake.Put("/skpr/test/things", "fpm")
+	fake.Throttle(1)
+
+	input := &cloudwatchlogs.GetLogEventsInput{
+		LogGroupName:  aws.String("/skpr/test/things"),
+		LogStreamName: aws.String("fpm"),
+	}
+
+	_, err := fake.GetLogEvents(context.Background(), input)
+	assert.ErrorIs(t, err, ErrThrottling)
+
+	_, err = fake.GetLogEvents(context.Background(), input)
+	assert.NoError(t, err)
+
+	input.LogStreamName = aws.String("nginx")
+
+	_, err = fake.GetLogEvents(context.Background(), input)
+	assert.ErrorContains(t, err, "ResourceNotFoundException")
+
+	assert.Equal(t, 3, fake.Calls["GetLogEvents"])
+}
+
+func TestFilterLogEvents(t *testing.T) {
+	fake := New()
+	fake.PageSize = 1
+	fake.Put("/skpr/test/things", "fpm",
+		Event(time.UnixMilli(1000), "GET /healthz 500"),
+		Event(time.UnixMilli(2000), "GET /admin 500"),
+		Event(time.UnixMilli(3000), "GET /admin 200"),
+	)
+	fake.Put("/skpr/test/things", "nginx", Event(time.UnixMilli(1500), "GET /admin 500"))
+
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:   aws.String("/skpr/test/things"),
+		LogStreamNames: []string{"fpm", "nginx"},
+		FilterPattern:  aws.String("500 -healthz"),
+	}
+
+	var messages []string
+
+	for {
+		output, err := fake.FilterLogEvents(context.Background(), input)
+		assert.NoError(t, err)
+
+		for _, event := range output.Events {
+			messages = append(messages, aws.ToString(event.LogStreamName)+": "+aws.ToString(event.Message))
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+
+		input.NextToken = output.NextToken
+	}
+
+	assert.Equal(t, []string{"nginx: GET /admin 500", "fpm: GET /admin 500"}, messages)
+
+	input.FilterPattern = aws.String(`{ $.status = 500 }`)
+	input.NextToken = nil
+
+	_, err := fake.FilterLogEvents(context.Background(), input)
+	assert.ErrorContains(t, err, "InvalidParameterException")
+}
+
+func TestParsePattern(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		message string
+		match   bool
+	}{
+		{pattern: "", message: "anything", match: true},
+		{pattern: "ERROR", message: "level=ERROR msg=failed", match: true},
+		{pattern: "ERROR", message: "level=error msg=failed", match: false},
+		{pattern: "ERROR timeout", message: "ERROR connection refused", match: false},
+		{pattern: "?ERROR ?WARN", message: "WARN disk 90%", match: true},
+		{pattern: "?ERROR ?WARN", message: "INFO started", match: false},
+		{pattern: `"status 500"`, message: "status 500 in 3ms", match: true},
+		{pattern: `"status 500"`, message: "500 status", match: false},
+		{pattern: "GET -healthz", message: "GET /healthz", match: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+"/"+test.message, func(t *testing.T) {
+			terms, err := parsePattern(test.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, test.match, terms.match(test.message))
+		})
+	}
+}
+
+func TestDescribeLogStreams(t *testing.T) {
+	fake := New()
+	fake.PageSize = 2
+	fake.Put("/skpr/test/things", "fpm-1", Event(time.UnixMilli(1000), "one"))
+	fake.Put("/skpr/test/things", "fpm-2", Event(time.UnixMilli(2000), "two"), Event(time.UnixMilli(3000), "three"))
+	fake.Put("/skpr/test/things", "fpm-3")
+	fake.Put("/skpr/test/things", "nginx", Event(time.UnixMilli(1000), "one"))
+
+	input := &cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName:        aws.String("/skpr/test/things"),
+		LogStreamNamePrefix: aws.String("fpm-"),
+	}
+
+	output, err := fake.DescribeLogStreams(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Len(t, output.LogStreams, 2)
+	assert.Equal(t, "fpm-2", aws.ToString(output.LogStreams[1].LogStreamName))
+	assert.Equal(t, int64(2000), aws.ToInt64(output.LogStreams[1].FirstEventTimestamp))
+	assert.Equal(t, int64(3000), aws.ToInt64(output.LogStreams[1].LastEventTimestamp))
+
+	input.NextToken = output.NextToken
+
+	output, err = fake.DescribeLogStreams(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Len(t, output.LogStreams, 1)
+	assert.Nil(t, output.LogStreams[0].FirstEventTimestamp)
+	assert.Nil(t, output.NextToken)
+}
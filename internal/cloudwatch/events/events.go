@@ -3,114 +3,285 @@ package events
 import (
 	"compress/gzip"
 	"context"
-	"encoding/csv"
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"os"
+	"hash"
+	"io"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/filter"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/redact"
 )
 
+// LogEventsAPI used to read the events of a stream. Satisfied by *cloudwatchlogs.Client.
+type LogEventsAPI interface {
+	GetLogEvents(ctx context.Context, params *cloudwatchlogs.GetLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetLogEventsOutput, error)
+	FilterLogEvents(ctx context.Context, params *cloudwatchlogs.FilterLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error)
+}
+
 type PackageInput struct {
 	GroupName  string
 	StreamName string
 	StartTime  int64
 	EndTime    int64
-	Directory  string
+	// Format of the package. One of FormatCSV or FormatJSONL. Defaults to FormatCSV.
+	Format string
+	// Columns written by FormatCSV. Defaults to DefaultColumns.
+	Columns []string
+	// Directory used to stage the package when Open is not set.
+	Directory string
+	// Open is called to open the output of each part when its first event is packaged eg. to stream it to S3.
+	// Defaults to a file in Directory.
+	Open Opener
+	// Limits at which the package is split into another part.
+	Limits Limits
+	// FilterPattern only packages the events which match a CloudWatch Logs filter pattern eg. "?ERROR ?WARN". Optional.
+	FilterPattern string
+	// Multiline merges continuation lines into the event before them, before Filter and Redactor. Optional.
+	Multiline *Multiline
+	// Filter drops events by their message before they are packaged. Optional.
+	Filter *filter.Filter
+	// Redactor removes sensitive values from the messages which pass Filter. Optional.
+	Redactor *redact.Redactor
+	// SkipUntilEventID skips events at StartTime up to and including the event with this ID.
+	// Used to resume from a checkpoint without exporting the same event twice.
+	SkipUntilEventID string
+	// NextToken continues a previous Package call which stopped early. StartTime and EndTime must match that call.
+	NextToken string
+	// StopBefore stops paging once reached so that the package can be finalised before the function times out.
+	StopBefore time.Time
 }
 
 type PackageOutput struct {
-	FilePath string
-	Count    int
+	// Parts which were written, in order.
+	Parts []Part
+	Count int
+	// Dropped is the number of events which were dropped by Filter or Redactor.
+	Dropped int
+	// Redactions counts the hits of each redaction rule.
+	Redactions map[string]int
+	// LastTimestamp of the last event which was processed.
+	LastTimestamp int64
+	// LastEventID of the last event which was processed.
+	LastEventID string
+	// Truncated is set when paging stopped at StopBefore before reaching the end of the stream.
+	Truncated bool
+	// NextToken to continue from when the package was truncated.
+	NextToken string
+}
+
+// EventID returns a stable identifier for an event. GetLogEvents doesn't return event IDs,
+// so one is derived from the origin and content of the event.
+func EventID(group, stream string, event types.OutputLogEvent) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%d\x00%s", group, stream, aws.ToInt64(event.Timestamp), aws.ToInt64(event.IngestionTime), aws.ToString(event.Message))
+	return hex.EncodeToString(hash.Sum(nil))[:32]
 }
 
-func Package(ctx context.Context, svc *cloudwatchlogs.Client, params PackageInput) (PackageOutput, bool, error) {
-	var hasEvents bool
+func Package(ctx context.Context, svc LogEventsAPI, params PackageInput) (output PackageOutput, hasEvents bool, err error) {
+	skipping := params.SkipUntilEventID != ""
 
-	input := &cloudwatchlogs.GetLogEventsInput{
-		LogGroupName:  aws.String(params.GroupName),
-		LogStreamName: aws.String(params.StreamName),
-		StartTime:     aws.Int64(params.StartTime),
-		EndTime:       aws.Int64(params.EndTime),
-		StartFromHead: aws.Bool(true),
+	if params.Redactor != nil {
+		output.Redactions = make(map[string]int)
 	}
 
-	output := PackageOutput{
-		FilePath: fmt.Sprintf("%s/%s.gz", params.Directory, params.StreamName),
+	var lines *merger
+
+	if params.Multiline != nil {
+		lines = &merger{config: params.Multiline}
 	}
 
-	file, err := os.Create(output.FilePath)
+	pages := newPager(svc, params)
+
+	open := params.Open
+
+	if open == nil {
+		open = func(part int) (Output, error) {
+			return CreateFile(stagedPath(params.Directory, params.StreamName, part))
+		}
+	}
+
+	var (
+		out       Output
+		zipWriter *gzip.Writer
+		encoder   Encoder
+		digest    hash.Hash
+		part      Part
+	)
+
+	// Discard anything which was partially written.
 	defer func() {
-		err = errors.Join(err, file.Close())
+		if err != nil && out != nil {
+			out.Abort(err)
+		}
 	}()
 
-	if err != nil {
-		return output, hasEvents, fmt.Errorf("failed to create file, %v", err)
-	}
+	// Finalises the part which is being written.
+	closePart := func() error {
+		if err := encoder.Flush(); err != nil {
+			return fmt.Errorf("failed to flush encoder, %v", err)
+		}
 
-	zipWriter := gzip.NewWriter(file)
-	csvwriter := csv.NewWriter(zipWriter)
+		if err := zipWriter.Close(); err != nil {
+			return fmt.Errorf("failed to close gzip writer, %v", err)
+		}
 
-	// https://github.com/Azure/Azure-Sentinel/blob/master/DataConnectors/AWS-S3/CloudWatchLanbdaFunction.py#L57C132-L57C143
-	csvwriter.Comma = ' '
+		part.SHA256 = hex.EncodeToString(digest.Sum(nil))
 
-	for {
-		resp, err := svc.GetLogEvents(ctx, input)
-		if err != nil {
-			return output, hasEvents, fmt.Errorf("failed to get log events, %v", err)
+		// Closing has finalised or discarded the output, there is nothing left to abort.
+		closeErr := out.Close()
+		out = nil
+
+		if closeErr != nil {
+			return fmt.Errorf("failed to close output, %v", closeErr)
 		}
 
-		count := len(resp.Events)
+		output.Parts = append(output.Parts, part)
 
-		// If we have events AND we have not marked this before.
-		if count > 0 && !hasEvents {
-			hasEvents = true
+		return nil
+	}
+
+	// Filters, redacts and encodes an event.
+	write := func(event types.OutputLogEvent, id string) error {
+		if !params.Filter.Keep(aws.ToString(event.Message)) {
+			output.Dropped++
+			return nil
 		}
 
-		// We don't have any more logs to write.
-		if count == 0 {
-			break
+		message, keep := params.Redactor.Redact(aws.ToString(event.Message), output.Redactions)
+		if !keep {
+			output.Dropped++
+			return nil
 		}
 
-		output.Count += len(resp.Events)
+		record := Event{
+			Timestamp:     time.UnixMilli(*event.Timestamp).UTC().Format(TimestampFormat),
+			IngestionTime: time.UnixMilli(aws.ToInt64(event.IngestionTime)).UTC().Format(TimestampFormat),
+			GroupName:     params.GroupName,
+			StreamName:    params.StreamName,
+			EventID:       id,
+			Message:       message,
+		}
+
+		// The output is only opened once there is something to write.
+		if out == nil {
+			part = Part{
+				Index:          len(output.Parts),
+				FirstTimestamp: *event.Timestamp,
+			}
 
-		for _, event := range resp.Events {
-			record := []string{
-				time.UnixMilli(*event.Timestamp).Format("2006-01-02T15:04:05.000Z"),
+			if params.Open == nil {
+				part.FilePath = stagedPath(params.Directory, params.StreamName, part.Index)
 			}
 
-			record = append(record, *event.Message)
+			var err error
 
-			if err := csvwriter.Write(record); err != nil {
-				return output, hasEvents, fmt.Errorf("failed to write log event to CSV, %v", err)
+			out, err = open(part.Index)
+			if err != nil {
+				return fmt.Errorf("failed to open output, %v", err)
+			}
+
+			digest = sha256.New()
+			zipWriter = gzip.NewWriter(countingWriter{w: io.MultiWriter(out, digest), n: &part.CompressedBytes})
+
+			encoder, err = NewEncoder(params.Format, params.Columns, countingWriter{w: zipWriter, n: &part.Bytes})
+			if err != nil {
+				return fmt.Errorf("failed to create encoder, %v", err)
 			}
 		}
 
-		// If you have reached the end of the stream, it returns the same token you passed in.
-		// We need to set and exit early here for another loop to know if this is the end.
-		if input.NextToken == nil {
-			input.NextToken = resp.NextForwardToken
-			break
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode log event, %v", err)
 		}
 
-		// If you have reached the end of the stream, CloudWatch Logs returns the same token you passed in.
-		if *resp.NextForwardToken == *input.NextToken {
+		// Keeps the byte counts up to date.
+		if err := encoder.Flush(); err != nil {
+			return fmt.Errorf("failed to flush encoder, %v", err)
+		}
+
+		part.LastTimestamp = *event.Timestamp
+		part.Count++
+		output.Count++
+		hasEvents = true
+
+		if params.Limits.reached(part) {
+			if err := closePart(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for {
+		// Leave enough time to finalise and upload what we have.
+		if !params.StopBefore.IsZero() && time.Now().After(params.StopBefore) {
+			output.Truncated = true
+			output.NextToken = pages.token()
 			break
 		}
 
-		input.NextToken = resp.NextForwardToken
+		page, done, err := pages.next(ctx)
+		if err != nil {
+			return output, hasEvents, err
+		}
+
+		for _, event := range page {
+			id := EventID(params.GroupName, params.StreamName, event)
+
+			if skipping {
+				if *event.Timestamp == params.StartTime {
+					skipping = id != params.SkipUntilEventID
+					continue
+				}
+
+				skipping = false
+			}
+
+			output.LastTimestamp = *event.Timestamp
+			output.LastEventID = id
+
+			// Dropped and merged events still move the checkpoint so that they aren't read again.
+			if lines == nil {
+				if err := write(event, id); err != nil {
+					return output, hasEvents, err
+				}
+
+				continue
+			}
+
+			if merged := lines.add(logEvent{event: event, id: id}); merged != nil {
+				if err := write(merged.event, merged.id); err != nil {
+					return output, hasEvents, err
+				}
+			}
+		}
+
+		if done {
+			break
+		}
 	}
 
-	csvwriter.Flush()
+	// The last event may still be merging continuation lines.
+	if lines != nil {
+		if merged := lines.flush(); merged != nil {
+			if err := write(merged.event, merged.id); err != nil {
+				return output, hasEvents, err
+			}
+		}
+	}
 
-	if err := zipWriter.Flush(); err != nil {
-		return output, hasEvents, fmt.Errorf("failed to flush gzip writer, %v", err)
+	if out == nil {
+		return output, hasEvents, nil
 	}
 
-	if err := zipWriter.Close(); err != nil {
-		return output, hasEvents, fmt.Errorf("failed to close gzip writer, %v", err)
+	if err := closePart(); err != nil {
+		return output, hasEvents, err
 	}
 
 	return output, hasEvents, nil
@@ -0,0 +1,396 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/cloudwatchtest"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/filter"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/redact"
+)
+
+var start = time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+// Returns a fake with n events in the fpm stream, one second apart.
+func newFake(n int) *cloudwatchtest.Fake {
+	fake := cloudwatchtest.New()
+	fake.Put("/skpr/test/things", "fpm")
+
+	for i := 0; i < n; i++ {
+		fake.Put("/skpr/test/things", "fpm", cloudwatchtest.Event(start.Add(time.Duration(i)*time.Second), fmt.Sprintf("message %d", i)))
+	}
+
+	return fake
+}
+
+// Returns the input used to package the first hour of the fpm stream.
+func newInput(t *testing.T) PackageInput {
+	return PackageInput{
+		GroupName:  "/skpr/test/things",
+		StreamName: "fpm",
+		StartTime:  start.UnixMilli(),
+		EndTime:    start.Add(time.Hour).UnixMilli(),
+		Format:     FormatJSONL,
+		Directory:  t.TempDir(),
+	}
+}
+
+// Returns the messages of a staged part.
+func readMessages(t *testing.T, path string) []string {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	zip, err := gzip.NewReader(file)
+	assert.NoError(t, err)
+
+	decoder, err := NewDecoder(FormatJSONL, nil, zip)
+	assert.NoError(t, err)
+
+	var messages []string
+
+	for {
+		event, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			return messages
+		}
+
+		assert.NoError(t, err)
+
+		messages = append(messages, event.Message)
+	}
+}
+
+func TestPackagePages(t *testing.T) {
+	fake := newFake(25)
+	fake.PageSize = 10
+	fake.EmptyPages = 1
+
+	output, hasEvents, err := Package(context.Background(), fake, newInput(t))
+	assert.NoError(t, err)
+	assert.True(t, hasEvents)
+	assert.Equal(t, 25, output.Count)
+	assert.False(t, output.Truncated)
+	assert.Equal(t, start.Add(24*time.Second).UnixMilli(), output.LastTimestamp)
+
+	// Three pages of events, each after an empty page, then the token is returned unchanged.
+	assert.Equal(t, 7, fake.Calls["GetLogEvents"])
+
+	assert.Len(t, output.Parts, 1)
+	assert.Equal(t, 25, output.Parts[0].Count)
+
+	messages := readMessages(t, output.Parts[0].FilePath)
+	assert.Len(t, messages, 25)
+	assert.Equal(t, "message 0", messages[0])
+	assert.Equal(t, "message 24", messages[24])
+}
+
+func TestPackageWindow(t *testing.T) {
+	fake := newFake(10)
+
+	input := newInput(t)
+	input.StartTime = start.Add(2 * time.Second).UnixMilli()
+	input.EndTime = start.Add(5 * time.Second).UnixMilli()
+
+	output, _, err := Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"message 2", "message 3", "message 4"}, readMessages(t, output.Parts[0].FilePath))
+}
+
+func TestPackageSkipUntil(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.Put("/skpr/test/things", "fpm",
+		cloudwatchtest.Event(start, "first"),
+		cloudwatchtest.Event(start, "second"),
+		cloudwatchtest.Event(start, "third"),
+		cloudwatchtest.Event(start.Add(time.Second), "fourth"),
+	)
+
+	input := newInput(t)
+
+	output, _, err := Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, output.Count)
+
+	// Resume after the second event, which shares its timestamp with the first and third.
+	input.Directory = t.TempDir()
+	input.SkipUntilEventID = EventID(input.GroupName, input.StreamName, cloudwatchtest.Event(start, "second"))
+
+	output, _, err = Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"third", "fourth"}, readMessages(t, output.Parts[0].FilePath))
+}
+
+func TestPackageFilterPattern(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.PageSize = 1
+	fake.EmptyPages = 1
+	fake.Put("/skpr/test/things", "fpm",
+		cloudwatchtest.Event(start, "GET /healthz 200"),
+		cloudwatchtest.Event(start.Add(time.Second), "POST /user/login 401"),
+		cloudwatchtest.Event(start.Add(2*time.Second), "GET /node/1 200"),
+		cloudwatchtest.Event(start.Add(3*time.Second), "GET /admin 500"),
+	)
+	fake.Put("/skpr/test/things", "nginx",
+		cloudwatchtest.Event(start, "GET /admin 500"),
+	)
+
+	input := newInput(t)
+	input.FilterPattern = "?401 ?500"
+
+	output, hasEvents, err := Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.True(t, hasEvents)
+	assert.Equal(t, 2, output.Count)
+	assert.Equal(t, []string{"POST /user/login 401", "GET /admin 500"}, readMessages(t, output.Parts[0].FilePath))
+
+	// Matching events are read with FilterLogEvents instead of GetLogEvents.
+	assert.Equal(t, 0, fake.Calls["GetLogEvents"])
+	assert.Equal(t, 4, fake.Calls["FilterLogEvents"])
+
+	// Events have the same IDs as when they are read with GetLogEvents, so checkpoints carry over.
+	assert.Equal(t, EventID(input.GroupName, input.StreamName, cloudwatchtest.Event(start.Add(3*time.Second), "GET /admin 500")), output.LastEventID)
+}
+
+func TestPackageFilter(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.Put("/skpr/test/things", "fpm",
+		cloudwatchtest.Event(start, "GET /healthz 200"),
+		cloudwatchtest.Event(start.Add(time.Second), `{"level":"debug","msg":"cache miss"}`),
+		cloudwatchtest.Event(start.Add(2*time.Second), `{"level":"error","msg":"cache down"}`),
+		cloudwatchtest.Event(start.Add(3*time.Second), "GET /healthz 200"),
+	)
+
+	rules, err := filter.New(filter.Config{
+		Exclude: []string{"^GET /healthz "},
+		Fields:  []string{`level != "debug"`},
+	})
+	assert.NoError(t, err)
+
+	input := newInput(t)
+	input.Filter = rules
+
+	output, hasEvents, err := Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.True(t, hasEvents)
+	assert.Equal(t, 1, output.Count)
+	assert.Equal(t, 3, output.Dropped)
+	assert.Equal(t, []string{`{"level":"error","msg":"cache down"}`}, readMessages(t, output.Parts[0].FilePath))
+
+	// Dropped events are still processed so that the checkpoint moves past them.
+	assert.Equal(t, start.Add(3*time.Second).UnixMilli(), output.LastTimestamp)
+
+	// Nothing is staged when every event is dropped.
+	input = newInput(t)
+	input.Filter, err = filter.New(filter.Config{Include: []string{"ERROR"}})
+	assert.NoError(t, err)
+
+	output, hasEvents, err = Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.False(t, hasEvents)
+	assert.Empty(t, output.Parts)
+	assert.Equal(t, 4, output.Dropped)
+	assert.Equal(t, start.Add(3*time.Second).UnixMilli(), output.LastTimestamp)
+}
+
+func TestPackageRedact(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.Put("/skpr/test/things", "fpm",
+		cloudwatchtest.Event(start, "GET /healthz 200"),
+		cloudwatchtest.Event(start.Add(time.Second), "login jane@example.com from 10.0.0.1"),
+		cloudwatchtest.Event(start.Add(2*time.Second), "charged 4111111111111111 for jane@example.com"),
+	)
+
+	redactor, err := redact.New(redact.Config{
+		Rules: []redact.Rule{
+			{Detector: redact.DetectorEmail},
+			{Detector: redact.DetectorIPv4},
+			{Detector: redact.DetectorPAN, Action: redact.ActionDrop},
+		},
+	})
+	assert.NoError(t, err)
+
+	input := newInput(t)
+	input.Filter, err = filter.New(filter.Config{Exclude: []string{"^GET /healthz "}})
+	assert.NoError(t, err)
+	input.Redactor = redactor
+
+	output, hasEvents, err := Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.True(t, hasEvents)
+	assert.Equal(t, 1, output.Count)
+	assert.Equal(t, 2, output.Dropped)
+	assert.Equal(t, map[string]int{"email": 2, "ipv4": 1, "pan": 1}, output.Redactions)
+	assert.Equal(t, []string{"login [REDACTED:email] from [REDACTED:ipv4]"}, readMessages(t, output.Parts[0].FilePath))
+
+	// Event IDs are derived from the original message, so checkpoints don't depend on the rules.
+	assert.Equal(t, EventID(input.GroupName, input.StreamName, cloudwatchtest.Event(start.Add(2*time.Second), "charged 4111111111111111 for jane@example.com")), output.LastEventID)
+}
+
+func TestPackageMultiline(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.PageSize = 2
+	fake.Put("/skpr/test/things", "fpm",
+		cloudwatchtest.Event(start, "Exception in thread \"main\" java.lang.IllegalStateException: boom"),
+		cloudwatchtest.Event(start.Add(time.Millisecond), "\tat com.example.App.run(App.java:12)"),
+		cloudwatchtest.Event(start.Add(2*time.Millisecond), "\tat com.example.App.main(App.java:5)"),
+		cloudwatchtest.Event(start.Add(time.Second), "2026-10-16 09:00:01 DEBUG started"),
+		cloudwatchtest.Event(start.Add(2*time.Second), "2026-10-16 09:00:02 INFO listening"),
+		cloudwatchtest.Event(start.Add(2*time.Second), "  on port 8080"),
+	)
+
+	input := newInput(t)
+	input.Multiline = &Multiline{Start: regexp.MustCompile(`^(\d{4}-|Exception)`)}
+	input.Filter, _ = filter.New(filter.Config{Exclude: []string{"DEBUG"}})
+
+	output, hasEvents, err := Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.True(t, hasEvents)
+	assert.Equal(t, 2, output.Count)
+	assert.Equal(t, 1, output.Dropped)
+	assert.Equal(t, []string{
+		"Exception in thread \"main\" java.lang.IllegalStateException: boom\n\tat com.example.App.run(App.java:12)\n\tat com.example.App.main(App.java:5)",
+		"2026-10-16 09:00:02 INFO listening\n  on port 8080",
+	}, readMessages(t, output.Parts[0].FilePath))
+
+	// Merged events keep the timestamp of their first line, while the checkpoint moves past every line.
+	assert.Equal(t, start.UnixMilli(), output.Parts[0].FirstTimestamp)
+	assert.Equal(t, start.Add(2*time.Second).UnixMilli(), output.Parts[0].LastTimestamp)
+	assert.Equal(t, EventID(input.GroupName, input.StreamName, cloudwatchtest.Event(start.Add(2*time.Second), "  on port 8080")), output.LastEventID)
+}
+
+func TestPackageWithoutEvents(t *testing.T) {
+	input := newInput(t)
+
+	output, hasEvents, err := Package(context.Background(), newFake(0), input)
+	assert.NoError(t, err)
+	assert.False(t, hasEvents)
+	assert.Empty(t, output.Parts)
+
+	// Nothing is staged when there is nothing to write.
+	entries, err := os.ReadDir(input.Directory)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPackageParts(t *testing.T) {
+	fake := newFake(25)
+
+	input := newInput(t)
+	input.Limits = Limits{Events: 10}
+
+	output, _, err := Package(context.Background(), fake, input)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, output.Count)
+	assert.Len(t, output.Parts, 3)
+
+	for i, count := range []int{10, 10, 5} {
+		part := output.Parts[i]
+
+		assert.Equal(t, i, part.Index)
+		assert.Equal(t, count, part.Count)
+		assert.Equal(t, filepath.Join(input.Directory, fmt.Sprintf("fpm.%d.gz", i)), part.FilePath)
+		assert.Equal(t, start.Add(time.Duration(i*10)*time.Second).UnixMilli(), part.FirstTimestamp)
+		assert.Equal(t, start.Add(time.Duration(i*10+count-1)*time.Second).UnixMilli(), part.LastTimestamp)
+
+		data, err := os.ReadFile(part.FilePath)
+		assert.NoError(t, err)
+
+		sum := sha256.Sum256(data)
+		assert.Equal(t, hex.EncodeToString(sum[:]), part.SHA256)
+		assert.Equal(t, int64(len(data)), part.CompressedBytes)
+	}
+
+	assert.Equal(t, "message 20", readMessages(t, output.Parts[2].FilePath)[0])
+}
+
+func TestPackageThrottled(t *testing.T) {
+	fake := newFake(25)
+	fake.PageSize = 10
+
+	// The second page is throttled.
+	fake.Errors = []error{nil, cloudwatchtest.ErrThrottling}
+
+	input := newInput(t)
+
+	output, hasEvents, err := Package(context.Background(), fake, input)
+	assert.ErrorContains(t, err, "ThrottlingException")
+	assert.True(t, hasEvents)
+	assert.Empty(t, output.Parts)
+
+	// The partially written part is discarded.
+	entries, err := os.ReadDir(input.Directory)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPackageStopBefore(t *testing.T) {
+	input := newInput(t)
+	input.StopBefore = time.Now().Add(-time.Minute)
+
+	output, hasEvents, err := Package(context.Background(), newFake(5), input)
+	assert.NoError(t, err)
+	assert.False(t, hasEvents)
+	assert.True(t, output.Truncated)
+}
+
+// Output which keeps what is written in memory.
+type bufferOutput struct {
+	bytes.Buffer
+	closed  bool
+	aborted error
+}
+
+func (o *bufferOutput) Close() error {
+	o.closed = true
+	return nil
+}
+
+func (o *bufferOutput) Abort(err error) {
+	o.aborted = err
+}
+
+func TestPackageOpen(t *testing.T) {
+	var outputs []*bufferOutput
+
+	input := newInput(t)
+	input.Limits = Limits{Events: 3}
+	input.Open = func(part int) (Output, error) {
+		assert.Equal(t, len(outputs), part)
+
+		out := &bufferOutput{}
+		outputs = append(outputs, out)
+
+		return out, nil
+	}
+
+	output, _, err := Package(context.Background(), newFake(5), input)
+	assert.NoError(t, err)
+	assert.Len(t, output.Parts, 2)
+	assert.Len(t, outputs, 2)
+
+	for i, out := range outputs {
+		assert.True(t, out.closed)
+		assert.NoError(t, out.aborted)
+		assert.Empty(t, output.Parts[i].FilePath)
+		assert.Equal(t, int64(out.Len()), output.Parts[i].CompressedBytes)
+	}
+
+	// Nothing is staged on the filesystem.
+	entries, err := os.ReadDir(input.Directory)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
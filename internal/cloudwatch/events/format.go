@@ -0,0 +1,241 @@
+package events
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const (
+	// FormatCSV is the space-separated CSV of timestamp and message read by the Sentinel S3 connector.
+	FormatCSV = "csv"
+	// FormatJSONL is one JSON object per event.
+	FormatJSONL = "jsonl"
+)
+
+const (
+	ColumnTimestamp     = "timestamp"
+	ColumnIngestionTime = "ingestion_time"
+	ColumnGroup         = "group"
+	ColumnStream        = "stream"
+	ColumnEventID       = "event_id"
+	ColumnMessage       = "message"
+)
+
+// DefaultColumns written by FormatCSV, as read by the Sentinel S3 connector.
+var DefaultColumns = []string{ColumnTimestamp, ColumnMessage}
+
+// TimestampFormat used for packaged timestamps.
+const TimestampFormat = "2006-01-02T15:04:05.000Z"
+
+// Event as written to a package.
+type Event struct {
+	Timestamp     string `json:"timestamp"`
+	IngestionTime string `json:"ingestion_time"`
+	GroupName     string `json:"group"`
+	StreamName    string `json:"stream"`
+	EventID       string `json:"event_id"`
+	Message       string `json:"message"`
+}
+
+// Returns the field of the event holding a column.
+func (e *Event) field(column string) *string {
+	switch column {
+	case ColumnTimestamp:
+		return &e.Timestamp
+	case ColumnIngestionTime:
+		return &e.IngestionTime
+	case ColumnGroup:
+		return &e.GroupName
+	case ColumnStream:
+		return &e.StreamName
+	case ColumnEventID:
+		return &e.EventID
+	case ColumnMessage:
+		return &e.Message
+	default:
+		return nil
+	}
+}
+
+// Encoder writes events in a format.
+type Encoder interface {
+	Encode(event Event) error
+	// Flush writes anything which has been buffered.
+	Flush() error
+}
+
+// Decoder reads events written by an Encoder. Fields which are not part of the format are left empty.
+type Decoder interface {
+	// Decode returns the next event or io.EOF.
+	Decode() (Event, error)
+}
+
+// CheckFormat returns an error if the format is not supported. An empty format is FormatCSV.
+func CheckFormat(format string) error {
+	switch format {
+	case "", FormatCSV, FormatJSONL:
+		return nil
+	default:
+		return fmt.Errorf("format must be one of %q or %q", FormatCSV, FormatJSONL)
+	}
+}
+
+// ContentType returns the media type of a format, before compression.
+func ContentType(format string) string {
+	if format == FormatJSONL {
+		return "application/x-ndjson"
+	}
+
+	return "text/csv"
+}
+
+// CheckColumns returns an error if the columns are unknown or repeated.
+func CheckColumns(columns []string) error {
+	seen := make(map[string]bool)
+
+	for _, column := range columns {
+		if (&Event{}).field(column) == nil {
+			return fmt.Errorf("unknown column %q", column)
+		}
+
+		if seen[column] {
+			return fmt.Errorf("column %q is repeated", column)
+		}
+
+		seen[column] = true
+	}
+
+	return nil
+}
+
+// Carries reports whether packages in the format carry a column. FormatJSONL carries every column, while FormatCSV
+// only carries its columns, which default to DefaultColumns.
+func Carries(format string, columns []string, column string) bool {
+	if format == FormatJSONL {
+		return true
+	}
+
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	return slices.Contains(columns, column)
+}
+
+// NewEncoder returns an encoder which writes the format. Defaults to FormatCSV.
+// Columns select the fields written by FormatCSV and default to DefaultColumns. FormatJSONL writes every field.
+func NewEncoder(format string, columns []string, w io.Writer) (Encoder, error) {
+	if err := CheckColumns(columns); err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	switch format {
+	case "", FormatCSV:
+		writer := csv.NewWriter(w)
+		// https://github.com/Azure/Azure-Sentinel/blob/master/DataConnectors/AWS-S3/CloudWatchLanbdaFunction.py#L57C132-L57C143
+		writer.Comma = ' '
+		return csvEncoder{writer: writer, columns: columns}, nil
+	case FormatJSONL:
+		return jsonlEncoder{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, CheckFormat(format)
+	}
+}
+
+// NewDecoder returns a decoder which reads the format. Columns must match those given to NewEncoder.
+func NewDecoder(format string, columns []string, r io.Reader) (Decoder, error) {
+	if err := CheckColumns(columns); err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+
+	switch format {
+	case "", FormatCSV:
+		reader := csv.NewReader(r)
+		reader.Comma = ' '
+		reader.FieldsPerRecord = len(columns)
+		return csvDecoder{reader: reader, columns: columns}, nil
+	case FormatJSONL:
+		return jsonlDecoder{decoder: json.NewDecoder(r)}, nil
+	default:
+		return nil, CheckFormat(format)
+	}
+}
+
+type csvEncoder struct {
+	writer  *csv.Writer
+	columns []string
+}
+
+func (e csvEncoder) Encode(event Event) error {
+	row := make([]string, len(e.columns))
+
+	for i, column := range e.columns {
+		row[i] = *event.field(column)
+	}
+
+	return e.writer.Write(row)
+}
+
+func (e csvEncoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type csvDecoder struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func (d csvDecoder) Decode() (Event, error) {
+	var event Event
+
+	row, err := d.reader.Read()
+	if err != nil {
+		return event, err
+	}
+
+	for i, column := range d.columns {
+		*event.field(column) = row[i]
+	}
+
+	return event, nil
+}
+
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e jsonlEncoder) Encode(event Event) error {
+	return e.encoder.Encode(event)
+}
+
+func (e jsonlEncoder) Flush() error {
+	return nil
+}
+
+type jsonlDecoder struct {
+	decoder *json.Decoder
+}
+
+func (d jsonlDecoder) Decode() (Event, error) {
+	var event Event
+
+	err := d.decoder.Decode(&event)
+	if errors.Is(err, io.EOF) {
+		return event, io.EOF
+	}
+
+	return event, err
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoders(t *testing.T) {
+	event := Event{
+		Timestamp:     "2026-10-16T00:00:00.000Z",
+		IngestionTime: "2026-10-16T00:00:01.000Z",
+		GroupName:     "/skpr/test/things",
+		StreamName:    "fpm",
+		EventID:       "0123456789abcdef0123456789abcdef",
+		Message:       `{"level": "info", "msg": "hello world"}`,
+	}
+
+	for _, tc := range []struct {
+		name    string
+		format  string
+		columns []string
+		want    string
+		// Fields which survive a round trip.
+		decoded Event
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			want:   "2026-10-16T00:00:00.000Z \"{\"\"level\"\": \"\"info\"\", \"\"msg\"\": \"\"hello world\"\"}\"\n",
+			decoded: Event{
+				Timestamp: event.Timestamp,
+				Message:   event.Message,
+			},
+		},
+		{
+			name:   "csv with every column",
+			format: FormatCSV,
+			columns: []string{
+				ColumnTimestamp,
+				ColumnIngestionTime,
+				ColumnGroup,
+				ColumnStream,
+				ColumnEventID,
+				ColumnMessage,
+			},
+			want:    "2026-10-16T00:00:00.000Z 2026-10-16T00:00:01.000Z /skpr/test/things fpm 0123456789abcdef0123456789abcdef \"{\"\"level\"\": \"\"info\"\", \"\"msg\"\": \"\"hello world\"\"}\"\n",
+			decoded: event,
+		},
+		{
+			name:    "jsonl",
+			format:  FormatJSONL,
+			want:    `{"timestamp":"2026-10-16T00:00:00.000Z","ingestion_time":"2026-10-16T00:00:01.000Z","group":"/skpr/test/things","stream":"fpm","event_id":"0123456789abcdef0123456789abcdef","message":"{\"level\": \"info\", \"msg\": \"hello world\"}"}` + "\n",
+			decoded: event,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			encoder, err := NewEncoder(tc.format, tc.columns, &buf)
+			assert.NoError(t, err)
+			assert.NoError(t, encoder.Encode(event))
+			assert.NoError(t, encoder.Flush())
+			assert.Equal(t, tc.want, buf.String())
+
+			decoder, err := NewDecoder(tc.format, tc.columns, &buf)
+			assert.NoError(t, err)
+
+			decoded, err := decoder.Decode()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.decoded, decoded)
+
+			_, err = decoder.Decode()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	assert.NoError(t, CheckFormat(""))
+	assert.NoError(t, CheckFormat(FormatCSV))
+	assert.NoError(t, CheckFormat(FormatJSONL))
+	assert.Error(t, CheckFormat("xml"))
+
+	_, err := NewEncoder("xml", nil, io.Discard)
+	assert.Error(t, err)
+}
+
+func TestCarries(t *testing.T) {
+	assert.True(t, Carries("", nil, ColumnMessage))
+	assert.False(t, Carries(FormatCSV, nil, ColumnStream))
+	assert.True(t, Carries(FormatCSV, []string{ColumnStream}, ColumnStream))
+	assert.False(t, Carries(FormatCSV, []string{ColumnStream}, ColumnMessage))
+	assert.True(t, Carries(FormatJSONL, []string{ColumnStream}, ColumnMessage))
+}
+
+func TestCheckColumns(t *testing.T) {
+	assert.NoError(t, CheckColumns(nil))
+	assert.NoError(t, CheckColumns([]string{ColumnEventID, ColumnMessage}))
+	assert.EqualError(t, CheckColumns([]string{"level"}), `unknown column "level"`)
+	assert.EqualError(t, CheckColumns([]string{ColumnMessage, ColumnMessage}), `column "message" is repeated`)
+}
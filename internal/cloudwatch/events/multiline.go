@@ -0,0 +1,87 @@
+package events
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Multiline merges continuation lines eg. of a stack trace into the event they continue.
+type Multiline struct {
+	// Start matches the first line of each event. Lines which don't match continue the event before them.
+	Start *regexp.Regexp
+	// MaxLines merged into a single event. Unlimited when zero.
+	MaxLines int
+	// MaxGap between consecutive lines of the same event. Unlimited when zero.
+	MaxGap time.Duration
+}
+
+// An event read from CloudWatch Logs along with its ID.
+type logEvent struct {
+	event types.OutputLogEvent
+	id    string
+}
+
+// Merges the events of a single stream, in order.
+type merger struct {
+	config *Multiline
+	// The event being merged. Keeps the timestamp and ID of its first line.
+	pending *logEvent
+	message strings.Builder
+	lines   int
+	last    int64
+}
+
+// Adds the next event. Returns the event which was pending when this one doesn't continue it.
+func (m *merger) add(next logEvent) *logEvent {
+	if m.pending != nil && m.continues(next) {
+		m.message.WriteString("\n")
+		m.message.WriteString(aws.ToString(next.event.Message))
+		m.lines++
+		m.last = aws.ToInt64(next.event.Timestamp)
+
+		return nil
+	}
+
+	done := m.flush()
+
+	m.pending = &next
+	m.message.WriteString(aws.ToString(next.event.Message))
+	m.lines = 1
+	m.last = aws.ToInt64(next.event.Timestamp)
+
+	return done
+}
+
+// Reports whether the next event continues the pending one.
+func (m *merger) continues(next logEvent) bool {
+	if m.config.Start.MatchString(aws.ToString(next.event.Message)) {
+		return false
+	}
+
+	if m.config.MaxLines > 0 && m.lines >= m.config.MaxLines {
+		return false
+	}
+
+	gap := time.Duration(aws.ToInt64(next.event.Timestamp)-m.last) * time.Millisecond
+
+	return m.config.MaxGap <= 0 || gap <= m.config.MaxGap
+}
+
+// Returns the pending event with its lines merged, if there is one.
+func (m *merger) flush() *logEvent {
+	if m.pending == nil {
+		return nil
+	}
+
+	done := m.pending
+	done.event.Message = aws.String(m.message.String())
+
+	m.pending = nil
+	m.message.Reset()
+
+	return done
+}
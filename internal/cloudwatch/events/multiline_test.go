@@ -0,0 +1,88 @@
+package events
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/cloudwatchtest"
+)
+
+func TestMerger(t *testing.T) {
+	// Lines of a PHP-FPM stack trace, relative to the first.
+	trace := []struct {
+		offset  time.Duration
+		message string
+	}{
+		{0, "[16-Oct-2026 09:00:00] PHP Fatal error: Uncaught Exception: boom"},
+		{0, "Stack trace:"},
+		{time.Millisecond, "#0 /code/web/index.php(19): main()"},
+		{3 * time.Second, "#1 {main}"},
+		{3 * time.Second, "[16-Oct-2026 09:00:03] PHP Notice: Undefined index"},
+	}
+
+	var tests = []struct {
+		name   string
+		config Multiline
+		want   []string
+	}{
+		{
+			name:   "Start of event",
+			config: Multiline{Start: regexp.MustCompile(`^\[`)},
+			want: []string{
+				"[16-Oct-2026 09:00:00] PHP Fatal error: Uncaught Exception: boom\nStack trace:\n#0 /code/web/index.php(19): main()\n#1 {main}",
+				"[16-Oct-2026 09:00:03] PHP Notice: Undefined index",
+			},
+		},
+		{
+			name:   "Max lines",
+			config: Multiline{Start: regexp.MustCompile(`^\[`), MaxLines: 2},
+			want: []string{
+				"[16-Oct-2026 09:00:00] PHP Fatal error: Uncaught Exception: boom\nStack trace:",
+				"#0 /code/web/index.php(19): main()\n#1 {main}",
+				"[16-Oct-2026 09:00:03] PHP Notice: Undefined index",
+			},
+		},
+		{
+			name:   "Max gap",
+			config: Multiline{Start: regexp.MustCompile(`^\[`), MaxGap: time.Second},
+			want: []string{
+				"[16-Oct-2026 09:00:00] PHP Fatal error: Uncaught Exception: boom\nStack trace:\n#0 /code/web/index.php(19): main()",
+				"#1 {main}",
+				"[16-Oct-2026 09:00:03] PHP Notice: Undefined index",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &merger{config: &tt.config}
+
+			var (
+				got        []string
+				timestamps []int64
+			)
+
+			collect := func(e *logEvent) {
+				if e != nil {
+					got = append(got, aws.ToString(e.event.Message))
+					timestamps = append(timestamps, aws.ToInt64(e.event.Timestamp))
+				}
+			}
+
+			for _, line := range trace {
+				collect(m.add(logEvent{event: cloudwatchtest.Event(start.Add(line.offset), line.message)}))
+			}
+
+			collect(m.flush())
+
+			assert.Equal(t, tt.want, got)
+
+			// Merged events keep the timestamp of their first line.
+			assert.Equal(t, start.UnixMilli(), timestamps[0])
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package events
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output receives a part of a package as it is written. It is opened when the first event of the part is packaged.
+type Output interface {
+	io.Writer
+	// Close finalises the output.
+	Close() error
+	// Abort discards the output after a failure.
+	Abort(err error)
+}
+
+// Opener opens the output for a part of a package. Parts are numbered from 0.
+type Opener func(part int) (Output, error)
+
+// Limits at which a part is closed and the next one is started. Zero is unlimited.
+// Limits are checked after each event, so a part may exceed them by up to one event.
+type Limits struct {
+	Events int
+	// Bytes before compression.
+	Bytes int64
+	// CompressedBytes is approximate as the compressor buffers what it writes.
+	CompressedBytes int64
+}
+
+// Reports whether a part has reached a limit.
+func (l Limits) reached(part Part) bool {
+	return (l.Events > 0 && part.Count >= l.Events) ||
+		(l.Bytes > 0 && part.Bytes >= l.Bytes) ||
+		(l.CompressedBytes > 0 && part.CompressedBytes >= l.CompressedBytes)
+}
+
+// Part of a package.
+type Part struct {
+	Index int
+	// FilePath of the staged part when Open was not set.
+	FilePath string
+	Count    int
+	// Bytes before compression.
+	Bytes           int64
+	CompressedBytes int64
+	// SHA256 of the compressed part, hex encoded.
+	SHA256 string
+	// FirstTimestamp and LastTimestamp of the events in the part, in milliseconds.
+	FirstTimestamp int64
+	LastTimestamp  int64
+}
+
+// FileOutput stages a package on the filesystem.
+type FileOutput struct {
+	*os.File
+}
+
+// Abort closes and removes the file.
+func (o FileOutput) Abort(error) {
+	o.File.Close()
+	os.Remove(o.File.Name())
+}
+
+// CreateFile stages a part at the given path.
+func CreateFile(path string) (Output, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return FileOutput{File: file}, nil
+}
+
+// Returns the path a part of a stream is staged at.
+func stagedPath(directory, stream string, part int) string {
+	// Stream names can contain slashes eg. ECS task streams.
+	return filepath.Join(directory, fmt.Sprintf("%s.%d.gz", strings.ReplaceAll(stream, "/", "_"), part))
+}
+
+// Counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+// Write to the underlying writer.
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
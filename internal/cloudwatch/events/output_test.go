@@ -0,0 +1,71 @@
+package events
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitsReached(t *testing.T) {
+	var tests = []struct {
+		name    string
+		limits  Limits
+		part    Part
+		reached bool
+	}{
+		{
+			name:   "Unlimited",
+			limits: Limits{},
+			part:   Part{Count: 1000000, Bytes: 1 << 40, CompressedBytes: 1 << 40},
+		},
+		{
+			name:    "Events",
+			limits:  Limits{Events: 10},
+			part:    Part{Count: 10},
+			reached: true,
+		},
+		{
+			name:   "Under events",
+			limits: Limits{Events: 10},
+			part:   Part{Count: 9},
+		},
+		{
+			name:    "Bytes",
+			limits:  Limits{Events: 10, Bytes: 1024},
+			part:    Part{Count: 1, Bytes: 2048},
+			reached: true,
+		},
+		{
+			name:    "Compressed bytes",
+			limits:  Limits{CompressedBytes: 1024},
+			part:    Part{Bytes: 8192, CompressedBytes: 1024},
+			reached: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.reached, test.limits.reached(test.part))
+		})
+	}
+}
+
+func TestCountingWriter(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		n   int64
+	)
+
+	w := countingWriter{w: &buf, n: &n}
+
+	w.Write([]byte("hello "))
+	w.Write([]byte("world"))
+
+	assert.Equal(t, int64(11), n)
+	assert.Equal(t, "hello world", buf.String())
+}
+
+func TestStagedPath(t *testing.T) {
+	assert.Equal(t, "/tmp/ecs_app_123.2.gz", stagedPath("/tmp", "ecs/app/123", 2))
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Pages through the events of a stream, oldest first.
+type pager interface {
+	// Returns the next page of events. Done is set once the end of the stream has been reached.
+	next(ctx context.Context) (events []types.OutputLogEvent, done bool, err error)
+	// Returns the token which continues from the next page.
+	token() string
+}
+
+// Returns the pager for the input. Streams are filtered when a pattern is set.
+func newPager(svc LogEventsAPI, params PackageInput) pager {
+	var token *string
+
+	if params.NextToken != "" {
+		token = aws.String(params.NextToken)
+	}
+
+	// Each stream is filtered on its own even though FilterLogEvents accepts up to 100 streams. Streams are packaged,
+	// delivered and checkpointed separately, and a token of a request covering several streams can't resume one of
+	// them when only its delivery failed.
+	if params.FilterPattern != "" {
+		return &filterPager{
+			svc: svc,
+			input: &cloudwatchlogs.FilterLogEventsInput{
+				LogGroupName:   aws.String(params.GroupName),
+				LogStreamNames: []string{params.StreamName},
+				FilterPattern:  aws.String(params.FilterPattern),
+				StartTime:      aws.Int64(params.StartTime),
+				EndTime:        aws.Int64(params.EndTime),
+				NextToken:      token,
+			},
+		}
+	}
+
+	return &getPager{
+		svc: svc,
+		input: &cloudwatchlogs.GetLogEventsInput{
+			LogGroupName:  aws.String(params.GroupName),
+			LogStreamName: aws.String(params.StreamName),
+			StartTime:     aws.Int64(params.StartTime),
+			EndTime:       aws.Int64(params.EndTime),
+			StartFromHead: aws.Bool(true),
+			NextToken:     token,
+		},
+	}
+}
+
+// Pages through every event of a stream with GetLogEvents.
+type getPager struct {
+	svc   LogEventsAPI
+	input *cloudwatchlogs.GetLogEventsInput
+}
+
+func (p *getPager) next(ctx context.Context) ([]types.OutputLogEvent, bool, error) {
+	resp, err := p.svc.GetLogEvents(ctx, p.input)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get log events, %w", err)
+	}
+
+	// If you have reached the end of the stream, CloudWatch Logs returns the same token you passed in.
+	// Empty pages can be returned before the end of the stream, so the token is the only reliable signal.
+	if resp.NextForwardToken == nil || (p.input.NextToken != nil && *resp.NextForwardToken == *p.input.NextToken) {
+		return resp.Events, true, nil
+	}
+
+	p.input.NextToken = resp.NextForwardToken
+
+	return resp.Events, false, nil
+}
+
+func (p *getPager) token() string {
+	return aws.ToString(p.input.NextToken)
+}
+
+// Pages through the events of a stream which match a filter pattern with FilterLogEvents.
+type filterPager struct {
+	svc   LogEventsAPI
+	input *cloudwatchlogs.FilterLogEventsInput
+}
+
+func (p *filterPager) next(ctx context.Context) ([]types.OutputLogEvent, bool, error) {
+	resp, err := p.svc.FilterLogEvents(ctx, p.input)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to filter log events, %w", err)
+	}
+
+	events := make([]types.OutputLogEvent, len(resp.Events))
+
+	for i, event := range resp.Events {
+		events[i] = types.OutputLogEvent{
+			Timestamp:     event.Timestamp,
+			IngestionTime: event.IngestionTime,
+			Message:       event.Message,
+		}
+	}
+
+	// Unlike GetLogEvents, the token is left out once every matching event has been returned.
+	// Pages can be empty while the search is still in progress.
+	if resp.NextToken == nil {
+		return events, true, nil
+	}
+
+	p.input.NextToken = resp.NextToken
+
+	return events, false, nil
+}
+
+func (p *filterPager) token() string {
+	return aws.ToString(p.input.NextToken)
+}
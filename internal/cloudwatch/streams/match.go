@@ -0,0 +1,96 @@
+package streams
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MatchExact matches a single stream by its full name.
+	MatchExact = "exact"
+	// MatchPrefix matches streams which start with the pattern.
+	MatchPrefix = "prefix"
+	// MatchGlob matches streams using "*" and "?" wildcards eg. "fpm-*".
+	MatchGlob = "glob"
+	// MatchRegex matches streams using a regular expression eg. "^ecs/app/.*".
+	MatchRegex = "regex"
+)
+
+// Matcher selects log streams by name.
+type Matcher struct {
+	// Prefix which all matching streams share, used to narrow down the DescribeLogStreams request.
+	Prefix string
+	regex  *regexp.Regexp
+}
+
+// NewMatcher returns a matcher for the given mode and pattern.
+func NewMatcher(mode, pattern string) (Matcher, error) {
+	switch mode {
+	case MatchExact, "":
+		return Matcher{
+			Prefix: pattern,
+			regex:  regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$"),
+		}, nil
+	case MatchPrefix:
+		return Matcher{
+			Prefix: pattern,
+			regex:  regexp.MustCompile("^" + regexp.QuoteMeta(pattern)),
+		}, nil
+	case MatchGlob:
+		return Matcher{
+			Prefix: globPrefix(pattern),
+			regex:  regexp.MustCompile(globToRegex(pattern)),
+		}, nil
+	case MatchRegex:
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return Matcher{}, fmt.Errorf("failed to compile stream pattern %q, %w", pattern, err)
+		}
+
+		return Matcher{regex: regex}, nil
+	}
+
+	return Matcher{}, fmt.Errorf("unknown stream match mode %q", mode)
+}
+
+// Match reports whether the stream name is selected by this matcher.
+func (m Matcher) Match(name string) bool {
+	if m.regex == nil {
+		return true
+	}
+
+	return m.regex.MatchString(name)
+}
+
+// Converts a glob into an anchored regular expression. Unlike path.Match, "*" also matches "/"
+// so that patterns like "ecs/*" select every task stream.
+func globToRegex(pattern string) string {
+	var sb strings.Builder
+
+	sb.WriteString("^")
+
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	return sb.String()
+}
+
+// Returns the literal portion of a glob before the first wildcard.
+func globPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		return pattern[:i]
+	}
+
+	return pattern
+}
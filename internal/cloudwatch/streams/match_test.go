@@ -0,0 +1,75 @@
+package streams
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatcher(t *testing.T) {
+	var tests = []struct {
+		name    string
+		mode    string
+		pattern string
+		prefix  string
+		matches []string
+		misses  []string
+	}{
+		{
+			name:    "Exact",
+			mode:    MatchExact,
+			pattern: "fpm",
+			prefix:  "fpm",
+			matches: []string{"fpm"},
+			misses:  []string{"fpm-1", "nginx"},
+		},
+		{
+			name:    "Prefix",
+			mode:    MatchPrefix,
+			pattern: "fpm-",
+			prefix:  "fpm-",
+			matches: []string{"fpm-1", "fpm-abc"},
+			misses:  []string{"fpm", "nginx-fpm-1"},
+		},
+		{
+			name:    "Glob",
+			mode:    MatchGlob,
+			pattern: "ecs/*/app-?",
+			prefix:  "ecs/",
+			matches: []string{"ecs/abc/app-1", "ecs/a/b/app-2"},
+			misses:  []string{"ecs/abc/app-10", "fargate/abc/app-1"},
+		},
+		{
+			name:    "Regex",
+			mode:    MatchRegex,
+			pattern: "^ecs/app/.*",
+			prefix:  "",
+			matches: []string{"ecs/app/123", "ecs/app/"},
+			misses:  []string{"ecs/other/123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := NewMatcher(tt.mode, tt.pattern)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.prefix, matcher.Prefix)
+
+			for _, name := range tt.matches {
+				assert.True(t, matcher.Match(name), name)
+			}
+
+			for _, name := range tt.misses {
+				assert.False(t, matcher.Match(name), name)
+			}
+		})
+	}
+}
+
+func TestMatcherErrors(t *testing.T) {
+	_, err := NewMatcher("wildcard", "fpm")
+	assert.Error(t, err)
+
+	_, err = NewMatcher(MatchRegex, "fpm-(")
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// LogStreamsAPI used to discover the streams of a group. Satisfied by *cloudwatchlogs.Client.
+type LogStreamsAPI interface {
+	DescribeLogStreams(ctx context.Context, params *cloudwatchlogs.DescribeLogStreamsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogStreamsOutput, error)
+}
+
+// ListInput used to discover log streams within a group.
+type ListInput struct {
+	GroupName string
+	StartTime int64
+	EndTime   int64
+	// Matcher used to select streams by name. The zero value selects every stream.
+	Matcher Matcher
+}
+
+// List returns the streams in a log group which match and have events within the [StartTime, EndTime) window.
+func List(ctx context.Context, svc LogStreamsAPI, params ListInput) ([]types.LogStream, error) {
+	var list []types.LogStream
+
+	input := &cloudwatchlogs.DescribeLogStreamsInput{
+		LogGroupName: aws.String(params.GroupName),
+	}
+
+	if params.Matcher.Prefix != "" {
+		input.LogStreamNamePrefix = aws.String(params.Matcher.Prefix)
+	}
+
+	paginator := cloudwatchlogs.NewDescribeLogStreamsPaginator(svc, input)
+
+	for paginator.HasMorePages() {
+		resp, err := paginator.NextPage(ctx)
+		if err != nil {
+			return list, fmt.Errorf("failed to describe log streams, %v", err)
+		}
+
+		for _, stream := range resp.LogStreams {
+			if !params.Matcher.Match(aws.ToString(stream.LogStreamName)) {
+				continue
+			}
+
+			if !InWindow(stream, params.StartTime, params.EndTime) {
+				continue
+			}
+
+			list = append(list, stream)
+		}
+	}
+
+	return list, nil
+}
+
+// Names returns the names of the given streams.
+func Names(list []types.LogStream) []string {
+	names := make([]string, len(list))
+
+	for i, stream := range list {
+		names[i] = aws.ToString(stream.LogStreamName)
+	}
+
+	return names
+}
+
+// InWindow reports whether a stream may have events within the [start, end) window.
+func InWindow(stream types.LogStream, start, end int64) bool {
+	// Streams which have never received an event don't have any timestamps.
+	if stream.FirstEventTimestamp == nil {
+		return false
+	}
+
+	if *stream.FirstEventTimestamp >= end {
+		return false
+	}
+
+	// LastEventTimestamp is eventually consistent and can lag behind by up to an hour,
+	// so we also consider the last ingestion time to avoid skipping active streams.
+	last := aws.ToInt64(stream.LastEventTimestamp)
+
+	if ingested := aws.ToInt64(stream.LastIngestionTime); ingested > last {
+		last = ingested
+	}
+
+	return last >= start
+}
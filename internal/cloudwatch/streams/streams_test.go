@@ -0,0 +1,96 @@
+package streams
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/cloudwatchtest"
+)
+
+func TestInWindow(t *testing.T) {
+	var tests = []struct {
+		name   string
+		stream types.LogStream
+		want   bool
+	}{
+		{
+			name: "Stream without events",
+			want: false,
+		},
+		{
+			name: "Stream with events inside the window",
+			stream: types.LogStream{
+				FirstEventTimestamp: aws.Int64(500),
+				LastEventTimestamp:  aws.Int64(1500),
+			},
+			want: true,
+		},
+		{
+			name: "Stream which starts after the window",
+			stream: types.LogStream{
+				FirstEventTimestamp: aws.Int64(2000),
+				LastEventTimestamp:  aws.Int64(3000),
+			},
+			want: false,
+		},
+		{
+			name: "Stream which finished before the window",
+			stream: types.LogStream{
+				FirstEventTimestamp: aws.Int64(100),
+				LastEventTimestamp:  aws.Int64(999),
+			},
+			want: false,
+		},
+		{
+			name: "Stream with a lagging last event timestamp",
+			stream: types.LogStream{
+				FirstEventTimestamp: aws.Int64(100),
+				LastEventTimestamp:  aws.Int64(200),
+				LastIngestionTime:   aws.Int64(1200),
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InWindow(tt.stream, 1000, 2000); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.PageSize = 1
+	fake.Put("/skpr/test/things", "fpm-1", cloudwatchtest.Event(time.UnixMilli(1000), "inside"))
+	fake.Put("/skpr/test/things", "fpm-2", cloudwatchtest.Event(time.UnixMilli(5000), "after"))
+	fake.Put("/skpr/test/things", "fpm-3")
+	fake.Put("/skpr/test/things", "nginx", cloudwatchtest.Event(time.UnixMilli(1000), "inside"))
+
+	matcher, err := NewMatcher(MatchGlob, "fpm-*")
+	assert.NoError(t, err)
+
+	list, err := List(context.Background(), fake, ListInput{
+		GroupName: "/skpr/test/things",
+		StartTime: 0,
+		EndTime:   2000,
+		Matcher:   matcher,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fpm-1"}, Names(list))
+
+	// Pages were narrowed down to the prefix of the pattern.
+	assert.Equal(t, 3, fake.Calls["DescribeLogStreams"])
+
+	fake.Throttle(1)
+
+	_, err = List(context.Background(), fake, ListInput{GroupName: "/skpr/test/things"})
+	assert.ErrorContains(t, err, "ThrottlingException")
+}
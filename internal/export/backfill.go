@@ -0,0 +1,129 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/checkpoint"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/util"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/window"
+)
+
+const (
+	// LogKeyBackfillChunkCount is the number of chunks in a backfill.
+	LogKeyBackfillChunkCount = "backfill_chunk_count"
+	// LogKeyBackfillResumeTime is the time an interrupted backfill resumes from.
+	LogKeyBackfillResumeTime = "backfill_resume_time"
+)
+
+// BackfillParams for a single job backfill.
+type BackfillParams struct {
+	Job                util.Job
+	From               time.Time
+	To                 time.Time
+	Chunk              time.Duration
+	TemporaryDirectory string
+	// Progress records the next unfinished chunk so an interrupted backfill resumes from it. Optional.
+	Progress checkpoint.Store
+	// StopBefore stops exporting once reached so that the function doesn't time out. Optional.
+	StopBefore time.Time
+	// Streaming delivers packages to sinks as they are written instead of staging them in TemporaryDirectory.
+	Streaming bool
+	// RunID identifies the run when naming objects. Optional.
+	RunID string
+}
+
+// Backfill exports the [From, To) range of a job one chunk at a time. Each chunk is uploaded as its own object.
+func Backfill(ctx context.Context, logger *slog.Logger, clients Clients, params BackfillParams) (Result, error) {
+	job := params.Job
+
+	result := Result{
+		Job:       job.Name,
+		GroupName: job.GroupName,
+	}
+
+	chunks := window.Split(params.From, params.To, params.Chunk)
+	key := checkpoint.BackfillKey(job.Name, params.From, params.To)
+
+	var resume time.Time
+
+	if params.Progress != nil {
+		progress, err := params.Progress.Get(ctx, key)
+		if err != nil && !errors.Is(err, checkpoint.ErrNotFound) {
+			return result, fmt.Errorf("failed to get backfill progress, %w", err)
+		}
+
+		if err == nil {
+			resume = time.UnixMilli(progress.Timestamp).UTC()
+		}
+	}
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Starting backfill",
+		slog.String(LogKeyJobName, job.Name),
+		slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+		slog.String(LogKeyCloudWatchLogsStreamStartTime, params.From.String()),
+		slog.String(LogKeyCloudWatchLogsStreamEndTime, params.To.String()),
+		slog.Int(LogKeyBackfillChunkCount, len(chunks)),
+		slog.String(LogKeyBackfillResumeTime, resume.String()))
+
+	for _, chunk := range chunks {
+		// Finished by a previous invocation.
+		if chunk.Start.Before(resume) {
+			continue
+		}
+
+		if stopping(params.StopBefore) {
+			result.Truncated = true
+			break
+		}
+
+		chunkResult, err := Run(ctx, logger, clients, Params{
+			Job:                job,
+			Start:              chunk.Start,
+			End:                chunk.End,
+			TemporaryDirectory: params.TemporaryDirectory,
+			StopBefore:         params.StopBefore,
+			Streaming:          params.Streaming,
+			RunID:              params.RunID,
+		})
+
+		result.Streams += chunkResult.Streams
+		result.Count += chunkResult.Count
+		result.Dropped += chunkResult.Dropped
+		result.Retries += chunkResult.Retries
+		result.merge(chunkResult)
+
+		if err != nil {
+			return result, fmt.Errorf("failed to backfill chunk %s to %s, %w", chunk.Start, chunk.End, err)
+		}
+
+		// The chunk is exported again in full by the next invocation, overwriting the partial upload.
+		if chunkResult.Truncated {
+			result.Truncated = true
+			break
+		}
+
+		result.Chunks++
+
+		if params.Progress != nil {
+			err := params.Progress.Put(ctx, key, checkpoint.Checkpoint{
+				Timestamp: chunk.End.UnixMilli(),
+			})
+			if err != nil {
+				return result, fmt.Errorf("failed to put backfill progress, %w", err)
+			}
+		}
+	}
+
+	if result.Truncated {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Stopping backfill before the function times out",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+			slog.Int(LogKeyBackfillChunkCount, result.Chunks))
+	}
+
+	return result, nil
+}
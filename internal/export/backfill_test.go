@@ -0,0 +1,74 @@
+package export
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/checkpoint"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/cloudwatchtest"
+)
+
+func TestBackfill(t *testing.T) {
+	var tests = []struct {
+		name string
+		// Start of the first chunk which a previous invocation didn't finish. Optional.
+		progress  time.Time
+		delivered []string
+		chunks    int
+	}{
+		{
+			name:      "Exports each chunk",
+			delivered: []string{"event 0", "event 1", "event 2"},
+			chunks:    3,
+		},
+		{
+			name:      "Skips chunks finished by a previous invocation",
+			progress:  start.Add(time.Hour),
+			delivered: []string{"event 1", "event 2"},
+			chunks:    2,
+		},
+		{
+			name:     "Finished",
+			progress: start.Add(3 * time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := cloudwatchtest.New()
+			fake.Put("/skpr/test/things", "fpm",
+				cloudwatchtest.Event(start.Add(30*time.Minute), "event 0"),
+				cloudwatchtest.Event(start.Add(90*time.Minute), "event 1"),
+				cloudwatchtest.Event(start.Add(150*time.Minute), "event 2"),
+			)
+
+			job := newJob("/skpr/test/things", t.TempDir())
+			progress := checkpoint.NewFileStore(t.TempDir())
+			key := checkpoint.BackfillKey(job.Name, start, start.Add(3*time.Hour))
+
+			if !tt.progress.IsZero() {
+				assert.NoError(t, progress.Put(context.Background(), key, checkpoint.Checkpoint{Timestamp: tt.progress.UnixMilli()}))
+			}
+
+			result, err := Backfill(context.Background(), logger, Clients{CloudWatchLogs: fake}, BackfillParams{
+				Job:                job,
+				From:               start,
+				To:                 start.Add(3 * time.Hour),
+				Chunk:              time.Hour,
+				TemporaryDirectory: t.TempDir(),
+				Progress:           progress,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.chunks, result.Chunks)
+			assert.Equal(t, len(tt.delivered), result.Count)
+			assert.Equal(t, tt.delivered, readDelivered(t, job.Sinks[0].Directory))
+
+			cp, err := progress.Get(context.Background(), key)
+			assert.NoError(t, err)
+			assert.Equal(t, start.Add(3*time.Hour).UnixMilli(), cp.Timestamp)
+		})
+	}
+}
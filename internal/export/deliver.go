@@ -0,0 +1,134 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/sink"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/util"
+)
+
+// Builds the sinks a job delivers to.
+func newSinks(job util.Job, clients Clients) ([]sink.Sink, error) {
+	var sinks []sink.Sink
+
+	for _, config := range job.Destinations() {
+		var s sink.Sink
+
+		switch config.Type {
+		case util.SinkS3:
+			s3 := newS3(config, clients)
+			s = s3
+
+			if config.QueueURL != "" {
+				s = sink.NewNotify(s3, clients.SQS, config.QueueURL, clients.Region)
+			}
+		case util.SinkFile:
+			s = sink.NewFile(config.Directory)
+		case util.SinkAzure:
+			s = sink.NewAzure(sink.AzureConfig{
+				Endpoint:      config.Endpoint,
+				RuleID:        config.RuleID,
+				StreamName:    config.StreamName,
+				TenantID:      config.TenantID,
+				ClientID:      config.ClientID,
+				ClientSecret:  config.Secret(),
+				AuthorityHost: config.AuthorityHost,
+			})
+		default:
+			return nil, fmt.Errorf("unknown sink type %q", config.Type)
+		}
+
+		sinks = append(sinks, withName(s, config))
+	}
+
+	return sinks, nil
+}
+
+// Builds the sinks manifests are written to. Only sinks which store objects as they are given accept manifests,
+// and queues are not notified as consumers expect packages of events.
+func newManifestSinks(job util.Job, clients Clients) []sink.Sink {
+	var sinks []sink.Sink
+
+	for _, config := range job.Destinations() {
+		switch config.Type {
+		case util.SinkS3:
+			sinks = append(sinks, withName(newS3(config, clients), config))
+		case util.SinkFile:
+			sinks = append(sinks, withName(sink.NewFile(config.Directory), config))
+		}
+	}
+
+	return sinks
+}
+
+// Builds an S3 sink.
+func newS3(config util.Sink, clients Clients) *sink.S3 {
+	return sink.NewS3(clients.Uploader, config.BucketName, config.BucketPrefix, sink.Object{
+		ServerSideEncryption: config.SSE,
+		KMSKeyID:             config.KMSKeyID,
+		StorageClass:         config.StorageClass,
+		TagBatch:             config.TagsBatch(),
+		Tags:                 config.Tags,
+		Metadata:             config.Metadata,
+		ContentType:          config.ContentType,
+		ContentEncoding:      config.ContentEncoding,
+	})
+}
+
+// Returns the sink under the name given by the config, if any.
+func withName(s sink.Sink, config util.Sink) sink.Sink {
+	if config.Name == "" {
+		return s
+	}
+
+	return named{Sink: s, name: config.Name}
+}
+
+// Sink which was given a name by the config.
+type named struct {
+	sink.Sink
+	name string
+}
+
+// Name of the sink.
+func (n named) Name() string {
+	return n.name
+}
+
+// Delivers a package which was staged on the filesystem to each sink in turn.
+func deliverFile(ctx context.Context, sinks []sink.Sink, batch sink.Batch, path string) []sink.Result {
+	results := make([]sink.Result, len(sinks))
+
+	for i, s := range sinks {
+		results[i] = sink.Result{
+			Sink: s.Name(),
+			Err:  writeFile(ctx, s, batch, path),
+		}
+	}
+
+	return results
+}
+
+// Writes a file to a sink.
+func writeFile(ctx context.Context, s sink.Sink, batch sink.Batch, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file %q, %w", path, err)
+	}
+	defer file.Close()
+
+	return s.Write(ctx, batch, file)
+}
+
+// Flattens the results of each part.
+func flatten(results [][]sink.Result) []sink.Result {
+	var all []sink.Result
+
+	for _, r := range results {
+		all = append(all, r...)
+	}
+
+	return all
+}
@@ -0,0 +1,687 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/checkpoint"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/streams"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/key"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/manifest"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/sink"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/throttle"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/util"
+)
+
+const (
+	// LogKeyJobName is the name of the export job.
+	LogKeyJobName = "job_name"
+	// LogKeyCloudWatchLogsGroupName is the name of the log stream.
+	LogKeyCloudWatchLogsGroupName = "cloudwatch_logs_group_name"
+	// LogKeyCloudWatchLogsStreamName is the name of the log stream.
+	LogKeyCloudWatchLogsStreamName = "cloudwatch_logs_stream_name"
+	// LogKeyCloudWatchLogsStreamCount is the number of log streams being exported.
+	LogKeyCloudWatchLogsStreamCount = "cloudwatch_logs_stream_count"
+	// LogKeyCloudWatchLogsStreamMatch is the mode used to match log streams.
+	LogKeyCloudWatchLogsStreamMatch = "cloudwatch_logs_stream_match"
+	// LogKeyCloudWatchLogsStreamPattern is the pattern used to match log streams.
+	LogKeyCloudWatchLogsStreamPattern = "cloudwatch_logs_stream_pattern"
+	// LogKeyCloudWatchLogsStreamsMatched is the list of log streams which matched.
+	LogKeyCloudWatchLogsStreamsMatched = "cloudwatch_logs_streams_matched"
+	// LogKeyCloudWatchLogsStreamStartTime is the start time of the log stream.
+	LogKeyCloudWatchLogsStreamStartTime = "cloudwatch_logs_stream_start_time"
+	// LogKeyCloudWatchLogsStreamEndTime is the finish time of the log stream.
+	LogKeyCloudWatchLogsStreamEndTime = "cloudwatch_logs_stream_end_time"
+	// LogKeyCloudWatchLogsStreamLogCount is the number of log events in the stream.
+	LogKeyCloudWatchLogsStreamLogCount = "cloudwatch_logs_stream_log_count"
+	// LogKeySinkName is the name of the sink a batch is delivered to.
+	LogKeySinkName = "sink_name"
+	// LogKeySinkKey is the key of the batch within the sink.
+	LogKeySinkKey = "sink_key"
+	// LogKeyPart is the index of a part of a package.
+	LogKeyPart = "part"
+	// LogKeyManifestObjectCount is the number of objects listed by a manifest.
+	LogKeyManifestObjectCount = "manifest_object_count"
+	// LogKeyTemporaryFilePath is the path to the temporary file.
+	LogKeyTemporaryFilePath = "temporary_file_path"
+	// LogKeyS3BucketName is the name of the S3 bucket.
+	LogKeyS3BucketName = "s3_bucket_name"
+	// LogKeyS3BucketKey is the key of the S3 object.
+	LogKeyS3BucketKey = "s3_bucket_key"
+	// LogKeyCloudWatchLogsStreamDroppedCount is the number of log events dropped by the filter or redaction rules of the job.
+	LogKeyCloudWatchLogsStreamDroppedCount = "cloudwatch_logs_stream_dropped_count"
+	// LogKeyRedactions is the number of hits of each redaction rule.
+	LogKeyRedactions = "redactions"
+	// LogKeyRetryCount is the number of retries of AWS requests.
+	LogKeyRetryCount = "retry_count"
+	// LogKeyError is the error which occurred.
+	LogKeyError = "error"
+)
+
+// CloudWatchLogsAPI used to discover streams and read their events. Satisfied by *cloudwatchlogs.Client.
+type CloudWatchLogsAPI interface {
+	events.LogEventsAPI
+	streams.LogStreamsAPI
+}
+
+// Clients used to export log events.
+type Clients struct {
+	// Client used to download and package CloudWatch Logs.
+	CloudWatchLogs CloudWatchLogsAPI
+	// Client used by S3 sinks.
+	Uploader *s3manager.Uploader
+	// Client used to notify queues of uploads.
+	SQS *sqs.Client
+	// Account and Region the function runs in, used to name objects and reported in notifications.
+	Account string
+	Region  string
+}
+
+// Params for a single job run.
+type Params struct {
+	Job                util.Job
+	Start              time.Time
+	End                time.Time
+	TemporaryDirectory string
+	// RunID identifies the run when naming objects. Retries of a run share an ID. Optional.
+	RunID string
+	// Checkpoints used to resume each stream from the last exported event. Optional.
+	Checkpoints checkpoint.Store
+	// Lookback is how far before Start streams are discovered when resuming from Checkpoints, so that streams which
+	// fell behind catch up without checking the checkpoint of every stream which ever had events.
+	// Defaults to util.DefaultMaxWindow.
+	Lookback time.Duration
+	// StopBefore stops exporting once reached so that the function doesn't time out. Optional.
+	StopBefore time.Time
+	// Streaming delivers packages to sinks as they are written instead of staging them in TemporaryDirectory.
+	Streaming bool
+}
+
+// Result of a single job run.
+type Result struct {
+	Job       string `json:"job"`
+	GroupName string `json:"group_name"`
+	Streams   int    `json:"streams"`
+	Count     int    `json:"count"`
+	Chunks    int    `json:"chunks,omitempty"`
+	// Dropped is the number of events which were dropped by the filter or redaction rules of the job.
+	Dropped int `json:"dropped,omitempty"`
+	// Redactions counts the hits of each redaction rule.
+	Redactions map[string]int `json:"redactions,omitempty"`
+	// Retries of AWS requests made by the job eg. when throttled.
+	Retries int `json:"retries,omitempty"`
+	// Truncated is set when the job stopped early to avoid a timeout.
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Sinks reports the batches delivered to each sink.
+	Sinks []SinkResult `json:"sinks,omitempty"`
+}
+
+// SinkResult counts the batches delivered to a single sink.
+type SinkResult struct {
+	Name      string `json:"name"`
+	Delivered int    `json:"delivered"`
+	Failed    int    `json:"failed,omitempty"`
+}
+
+// Adds the hits of each redaction rule.
+func (r *Result) redacted(hits map[string]int) {
+	for name, count := range hits {
+		if r.Redactions == nil {
+			r.Redactions = make(map[string]int)
+		}
+
+		r.Redactions[name] += count
+	}
+}
+
+// Records the outcome of delivering a batch to each sink.
+func (r *Result) record(deliveries []sink.Result) {
+	for _, delivery := range deliveries {
+		i := slices.IndexFunc(r.Sinks, func(s SinkResult) bool {
+			return s.Name == delivery.Sink
+		})
+
+		if i < 0 {
+			r.Sinks = append(r.Sinks, SinkResult{Name: delivery.Sink})
+			i = len(r.Sinks) - 1
+		}
+
+		if delivery.Err != nil {
+			r.Sinks[i].Failed++
+		} else {
+			r.Sinks[i].Delivered++
+		}
+	}
+}
+
+// Merges the sink results of another run.
+func (r *Result) merge(other Result) {
+	for _, s := range other.Sinks {
+		i := slices.IndexFunc(r.Sinks, func(existing SinkResult) bool {
+			return existing.Name == s.Name
+		})
+
+		if i < 0 {
+			r.Sinks = append(r.Sinks, s)
+			continue
+		}
+
+		r.Sinks[i].Delivered += s.Delivered
+		r.Sinks[i].Failed += s.Failed
+	}
+
+	r.redacted(other.Redactions)
+}
+
+// Position within a stream to export from.
+type position struct {
+	Start     int64
+	End       int64
+	SkipUntil string
+	NextToken string
+}
+
+// Run exports all streams selected by a job.
+func Run(ctx context.Context, logger *slog.Logger, clients Clients, params Params) (result Result, err error) {
+	job := params.Job
+
+	result = Result{
+		Job:       job.Name,
+		GroupName: job.GroupName,
+	}
+
+	ctx, retries := throttle.WithCounter(ctx)
+
+	defer func() {
+		result.Retries = retries.Count()
+	}()
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Executing job",
+		slog.String(LogKeyJobName, job.Name),
+		slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+		slog.String(LogKeyCloudWatchLogsStreamStartTime, params.Start.String()),
+		slog.String(LogKeyCloudWatchLogsStreamEndTime, params.End.String()),
+		slog.String(LogKeyS3BucketName, job.BucketName))
+
+	sinks, err := newSinks(job, clients)
+	if err != nil {
+		return result, fmt.Errorf("failed to build sinks, %w", err)
+	}
+
+	// Jobs can run at the same time and share stream names, so each run stages in its own directory.
+	if !params.Streaming {
+		params.TemporaryDirectory, err = os.MkdirTemp(params.TemporaryDirectory, "run-*")
+		if err != nil {
+			return result, fmt.Errorf("failed to create temporary directory, %w", err)
+		}
+
+		defer os.RemoveAll(params.TemporaryDirectory)
+	}
+
+	list := []types.LogStream{
+		{
+			LogStreamName: aws.String(job.StreamName),
+		},
+	}
+
+	if job.DiscoverStreams() {
+		var matcher streams.Matcher
+
+		if !job.AllStreams {
+			matcher, err = streams.NewMatcher(job.StreamMatch, job.StreamName)
+			if err != nil {
+				return result, fmt.Errorf("failed to build stream matcher, %w", err)
+			}
+		}
+
+		input := streams.ListInput{
+			GroupName: job.GroupName,
+			StartTime: params.Start.UnixMilli(),
+			EndTime:   params.End.UnixMilli(),
+			Matcher:   matcher,
+		}
+
+		// Streams with a checkpoint resume from it, so streams which fell behind are in the window as well.
+		if params.Checkpoints != nil {
+			lookback := params.Lookback
+			if lookback <= 0 {
+				lookback = util.DefaultMaxWindow
+			}
+
+			input.StartTime = params.Start.Add(-lookback).UnixMilli()
+		}
+
+		list, err = streams.List(ctx, clients.CloudWatchLogs, input)
+		if err != nil {
+			return result, fmt.Errorf("failed to list log streams, %w", err)
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "Discovered log streams with events",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+			slog.String(LogKeyCloudWatchLogsStreamMatch, job.StreamMatch),
+			slog.String(LogKeyCloudWatchLogsStreamPattern, job.StreamName),
+			slog.Int(LogKeyCloudWatchLogsStreamCount, len(list)),
+			slog.Any(LogKeyCloudWatchLogsStreamsMatched, streams.Names(list)))
+	}
+
+	var m *manifest.Manifest
+
+	if job.Manifests() {
+		m = &manifest.Manifest{
+			Job:       job.Name,
+			GroupName: job.GroupName,
+			RunID:     params.RunID,
+			Start:     params.Start,
+			End:       params.End,
+		}
+	}
+
+	var errs []error
+
+	for _, stream := range list {
+		streamName := aws.ToString(stream.LogStreamName)
+
+		if stopping(params.StopBefore) {
+			result.Truncated = true
+
+			logger.LogAttrs(ctx, slog.LevelWarn, "Stopping before the function times out",
+				slog.String(LogKeyJobName, job.Name),
+				slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+				slog.String(LogKeyCloudWatchLogsStreamName, streamName))
+
+			break
+		}
+
+		output, deliveries, err := runStream(ctx, logger, clients, params, sinks, stream, m)
+
+		result.record(deliveries)
+
+		if err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to export log stream",
+				slog.String(LogKeyJobName, job.Name),
+				slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+				slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+				slog.String(LogKeyError, err.Error()))
+
+			errs = append(errs, fmt.Errorf("stream %q: %w", streamName, err))
+
+			continue
+		}
+
+		result.Streams++
+		result.Count += output.Count
+		result.Dropped += output.Dropped
+		result.redacted(output.Redactions)
+
+		if output.Truncated {
+			result.Truncated = true
+		}
+	}
+
+	if m != nil {
+		m.Truncated = result.Truncated
+
+		if err := writeManifest(ctx, logger, clients, params, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if result.Truncated && params.Checkpoints == nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Job stopped early without a checkpoint store. Remaining events will not be exported.",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName))
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// Reports whether there is no time left to start more work.
+func stopping(stopBefore time.Time) bool {
+	return !stopBefore.IsZero() && time.Now().After(stopBefore)
+}
+
+// Resumes a single stream from its checkpoint, if any, and exports it. Delivered objects are added to the manifest, if any.
+func runStream(ctx context.Context, logger *slog.Logger, clients Clients, params Params, sinks []sink.Sink, stream types.LogStream, m *manifest.Manifest) (events.PackageOutput, []sink.Result, error) {
+	var output events.PackageOutput
+
+	streamName := aws.ToString(stream.LogStreamName)
+
+	pos := position{
+		Start: params.Start.UnixMilli(),
+		End:   params.End.UnixMilli(),
+	}
+
+	var last *checkpoint.Checkpoint
+
+	if params.Checkpoints != nil {
+		cp, err := params.Checkpoints.Get(ctx, checkpoint.Key(params.Job.Name, params.Job.GroupName, streamName))
+		if err != nil && !errors.Is(err, checkpoint.ErrNotFound) {
+			return output, nil, fmt.Errorf("failed to get checkpoint, %w", err)
+		}
+
+		if err == nil {
+			last = &cp
+			pos = afterCheckpoint(cp, pos.End)
+		}
+	}
+
+	windowStart := pos.Start
+
+	// The checkpointed event has already been exported.
+	if pos.SkipUntil != "" {
+		windowStart++
+	}
+
+	if windowStart >= pos.End {
+		return output, nil, nil
+	}
+
+	if params.Job.DiscoverStreams() && pos.NextToken == "" && !streams.InWindow(stream, windowStart, pos.End) {
+		return output, nil, nil
+	}
+
+	output, deliveries, err := exportStream(ctx, logger, clients, params, sinks, streamName, pos, m)
+	var invalid *types.InvalidParameterException
+
+	if err != nil && pos.NextToken != "" && deliveries == nil && errors.As(err, &invalid) {
+		// Tokens expire and are then rejected as invalid, so fall back to resuming from the last exported event. Other
+		// errors, such as throttling, are returned so that the next run continues from the token.
+		logger.LogAttrs(ctx, slog.LevelWarn, "Failed to continue from next token. Resuming from the last exported event.",
+			slog.String(LogKeyJobName, params.Job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, params.Job.GroupName),
+			slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+			slog.String(LogKeyError, err.Error()))
+
+		last.NextToken = ""
+
+		return exportStream(ctx, logger, clients, params, sinks, streamName, afterCheckpoint(*last, params.End.UnixMilli()), m)
+	}
+
+	return output, deliveries, err
+}
+
+// Returns the position to resume from after a checkpoint.
+func afterCheckpoint(cp checkpoint.Checkpoint, end int64) position {
+	// The previous run stopped early, continue paging through its window.
+	if cp.NextToken != "" {
+		return position{
+			Start:     cp.StartTime,
+			End:       cp.EndTime,
+			NextToken: cp.NextToken,
+		}
+	}
+
+	return position{
+		Start:     cp.Timestamp,
+		End:       end,
+		SkipUntil: cp.EventID,
+	}
+}
+
+// Package a single log stream and deliver it to each sink.
+func exportStream(ctx context.Context, logger *slog.Logger, clients Clients, params Params, sinks []sink.Sink, streamName string, pos position, m *manifest.Manifest) (events.PackageOutput, []sink.Result, error) {
+	job := params.Job
+
+	logger.LogAttrs(ctx, slog.LevelInfo, "Packaging log events",
+		slog.String(LogKeyJobName, job.Name),
+		slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+		slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+		slog.String(LogKeyCloudWatchLogsStreamStartTime, time.UnixMilli(pos.Start).UTC().String()),
+		slog.String(LogKeyCloudWatchLogsStreamEndTime, time.UnixMilli(pos.End).UTC().String()))
+
+	// Keys are named after the window of the run, so that retries overwrite rather than duplicate.
+	vars := key.Vars{
+		Job:     job.Name,
+		Group:   job.GroupName,
+		Stream:  streamName,
+		Account: clients.Account,
+		Region:  clients.Region,
+		RunID:   params.RunID,
+		Format:  format(job),
+		Start:   params.Start,
+		End:     params.End,
+	}
+
+	// Returns the batch for a part of the package.
+	batchFor := func(part int) (sink.Batch, error) {
+		vars.Part = part
+
+		name, err := key.Render(keyTemplate(job), vars)
+		if err != nil {
+			return sink.Batch{}, fmt.Errorf("failed to render key, %w", err)
+		}
+
+		return sink.Batch{
+			Key:        name,
+			GroupName:  job.GroupName,
+			StreamName: streamName,
+			Format:     job.Format,
+			Columns:    job.Columns,
+			Start:      time.UnixMilli(pos.Start).UTC(),
+			End:        time.UnixMilli(pos.End).UTC(),
+		}, nil
+	}
+
+	multiline, err := job.Multiline.Compile()
+	if err != nil {
+		return events.PackageOutput{}, nil, fmt.Errorf("failed to compile multiline, %w", err)
+	}
+
+	rules, err := job.Filter.Compile()
+	if err != nil {
+		return events.PackageOutput{}, nil, fmt.Errorf("failed to compile filter, %w", err)
+	}
+
+	redactor, err := job.Redact.Compile()
+	if err != nil {
+		return events.PackageOutput{}, nil, fmt.Errorf("failed to compile redaction rules, %w", err)
+	}
+
+	input := events.PackageInput{
+		GroupName:        job.GroupName,
+		StreamName:       streamName,
+		StartTime:        pos.Start,
+		EndTime:          pos.End,
+		Format:           job.Format,
+		Columns:          job.Columns,
+		FilterPattern:    job.FilterPattern,
+		Multiline:        multiline,
+		Filter:           rules,
+		Redactor:         redactor,
+		Directory:        params.TemporaryDirectory,
+		Limits:           job.Limits(),
+		SkipUntilEventID: pos.SkipUntil,
+		NextToken:        pos.NextToken,
+		StopBefore:       params.StopBefore,
+	}
+
+	var (
+		batches []sink.Batch
+		fanouts []*sink.Fanout
+	)
+
+	if params.Streaming {
+		input.Open = func(part int) (events.Output, error) {
+			batch, err := batchFor(part)
+			if err != nil {
+				return nil, err
+			}
+
+			fanout := sink.NewFanout(ctx, sinks, batch)
+
+			batches = append(batches, batch)
+			fanouts = append(fanouts, fanout)
+
+			return fanout, nil
+		}
+	}
+
+	output, hasEvents, err := events.Package(ctx, clients.CloudWatchLogs, input)
+
+	// Multiple streams are staged in the same directory, so clean up as we go.
+	for _, part := range output.Parts {
+		if part.FilePath != "" {
+			defer os.Remove(part.FilePath)
+		}
+	}
+
+	// Results of each part, in the same order as batches.
+	var results [][]sink.Result
+
+	for _, fanout := range fanouts {
+		results = append(results, fanout.Results())
+	}
+
+	if err != nil {
+		// Parts which were streamed before the failure are still listed.
+		recordObjects(m, output.Parts, batches, results)
+
+		return output, flatten(results), fmt.Errorf("failed to package log events, %w", err)
+	}
+
+	if len(output.Redactions) > 0 {
+		logger.LogAttrs(ctx, slog.LevelInfo, "Redacted log events",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+			slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+			slog.Any(LogKeyRedactions, output.Redactions))
+	}
+
+	if output.Dropped > 0 {
+		logger.LogAttrs(ctx, slog.LevelInfo, "Dropped log events which didn't pass the filter or redaction rules",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+			slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+			slog.Int(LogKeyCloudWatchLogsStreamDroppedCount, output.Dropped))
+	}
+
+	// Streams where every event was dropped still move their checkpoint.
+	if !hasEvents && output.Dropped == 0 {
+		logger.LogAttrs(ctx, slog.LevelInfo, "Stream does not have events. Skipping.",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+			slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+			slog.Int(LogKeyCloudWatchLogsStreamLogCount, output.Count))
+		return output, nil, nil
+	}
+
+	if output.Truncated {
+		logger.LogAttrs(ctx, slog.LevelWarn, "Stopped paging before the function times out. Delivering what has been packaged.",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+			slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+			slog.Int(LogKeyCloudWatchLogsStreamLogCount, output.Count))
+	}
+
+	if !params.Streaming {
+		for _, part := range output.Parts {
+			logger.LogAttrs(ctx, slog.LevelInfo, "Successfully packaged log events to filesystem",
+				slog.String(LogKeyJobName, job.Name),
+				slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+				slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+				slog.String(LogKeyTemporaryFilePath, part.FilePath),
+				slog.Int(LogKeyPart, part.Index),
+				slog.Int(LogKeyCloudWatchLogsStreamLogCount, part.Count))
+
+			batch, err := batchFor(part.Index)
+			if err != nil {
+				return output, flatten(results), err
+			}
+
+			// The count is only known up front when the part was staged.
+			batch.Count = part.Count
+
+			batches = append(batches, batch)
+			results = append(results, deliverFile(ctx, sinks, batch, part.FilePath))
+		}
+	}
+
+	for i, batch := range batches {
+		for _, delivery := range results[i] {
+			if delivery.Err != nil {
+				logger.LogAttrs(ctx, slog.LevelError, "Failed to deliver log events to sink",
+					slog.String(LogKeyJobName, job.Name),
+					slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+					slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+					slog.String(LogKeySinkName, delivery.Sink),
+					slog.String(LogKeySinkKey, batch.Key),
+					slog.String(LogKeyError, delivery.Err.Error()))
+				continue
+			}
+
+			logger.LogAttrs(ctx, slog.LevelInfo, "Finished delivering log events to sink",
+				slog.String(LogKeyJobName, job.Name),
+				slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+				slog.String(LogKeyCloudWatchLogsStreamName, streamName),
+				slog.Int(LogKeyCloudWatchLogsStreamLogCount, output.Parts[i].Count),
+				slog.String(LogKeySinkName, delivery.Sink),
+				slog.String(LogKeySinkKey, batch.Key))
+		}
+	}
+
+	recordObjects(m, output.Parts, batches, results)
+
+	deliveries := flatten(results)
+
+	// The checkpoint is not moved so that sinks which failed receive the events on the next run.
+	if failed := sink.Failed(deliveries); len(failed) > 0 {
+		var errs []error
+
+		for _, f := range failed {
+			errs = append(errs, fmt.Errorf("sink %q: %w", f.Sink, f.Err))
+		}
+
+		return output, deliveries, fmt.Errorf("failed to deliver log events, %w", errors.Join(errs...))
+	}
+
+	if params.Checkpoints != nil {
+		cp := checkpoint.Checkpoint{
+			Timestamp: output.LastTimestamp,
+			EventID:   output.LastEventID,
+		}
+
+		if output.Truncated {
+			cp.NextToken = output.NextToken
+			cp.StartTime = pos.Start
+			cp.EndTime = pos.End
+		}
+
+		err := params.Checkpoints.Put(ctx, checkpoint.Key(job.Name, job.GroupName, streamName), cp)
+		if err != nil {
+			return output, deliveries, fmt.Errorf("failed to put checkpoint, %w", err)
+		}
+	}
+
+	return output, deliveries, nil
+}
+
+// Returns the template used to name the objects of a job.
+func keyTemplate(job util.Job) string {
+	if job.KeyTemplate == "" {
+		return key.DefaultTemplate
+	}
+
+	return job.KeyTemplate
+}
+
+// Returns the format the events of a job are packaged in.
+func format(job util.Job) string {
+	if job.Format == "" {
+		return events.FormatCSV
+	}
+
+	return job.Format
+}
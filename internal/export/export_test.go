@@ -0,0 +1,414 @@
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/checkpoint"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/cloudwatchtest"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/manifest"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/util"
+)
+
+var start = time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Returns a job which exports the fpm stream to a file sink in the directory.
+func newJob(name, directory string) util.Job {
+	return util.Job{
+		Name:       name,
+		GroupName:  "/skpr/test/things",
+		StreamName: "fpm",
+		Format:     events.FormatJSONL,
+		Sinks: []util.Sink{
+			{
+				Type:      util.SinkFile,
+				Directory: directory,
+			},
+		},
+	}
+}
+
+// Returns the params of a run of the job over the hour after start.
+func newParams(t *testing.T, job util.Job, checkpoints checkpoint.Store) Params {
+	return Params{
+		Job:                job,
+		Start:              start,
+		End:                start.Add(time.Hour),
+		TemporaryDirectory: t.TempDir(),
+		Checkpoints:        checkpoints,
+	}
+}
+
+// Returns the messages of every package delivered below a directory, in the order of their keys.
+func readDelivered(t *testing.T, directory string) []string {
+	var messages []string
+
+	err := filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".gz") {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		zip, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+
+		decoder, err := events.NewDecoder(events.FormatJSONL, nil, zip)
+		if err != nil {
+			return err
+		}
+
+		for {
+			event, err := decoder.Decode()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			messages = append(messages, event.Message)
+		}
+	})
+	assert.NoError(t, err)
+
+	return messages
+}
+
+// Returns events in the fpm stream, one minute apart, and a fake which pages them two at a time.
+func newFake(messages ...string) (*cloudwatchtest.Fake, []types.OutputLogEvent) {
+	fake := cloudwatchtest.New()
+	fake.PageSize = 2
+
+	var list []types.OutputLogEvent
+
+	for i, message := range messages {
+		list = append(list, cloudwatchtest.Event(start.Add(time.Duration(i)*time.Minute), message))
+	}
+
+	fake.Put("/skpr/test/things", "fpm", list...)
+
+	return fake, list
+}
+
+// Returns the checkpoint of the fpm stream after an event.
+func after(event types.OutputLogEvent) checkpoint.Checkpoint {
+	return checkpoint.Checkpoint{
+		Timestamp: *event.Timestamp,
+		EventID:   events.EventID("/skpr/test/things", "fpm", event),
+	}
+}
+
+func TestRunCheckpoints(t *testing.T) {
+	_, list := newFake("event 0", "event 1", "event 2", "event 3", "event 4")
+
+	// The next token a run which stopped after the first page recorded.
+	truncated := after(list[1])
+	truncated.NextToken = "f/2/0"
+	truncated.StartTime = start.UnixMilli()
+	truncated.EndTime = start.Add(time.Hour).UnixMilli()
+
+	expired := truncated
+	expired.NextToken = "expired"
+
+	resumed := after(list[1])
+
+	var tests = []struct {
+		name string
+		// Checkpoint recorded by a previous run. Optional.
+		checkpoint *checkpoint.Checkpoint
+		// Adds a sink which fails to write.
+		broken bool
+		// Requests to CloudWatch Logs which are throttled.
+		throttles int
+		delivered []string
+		want      checkpoint.Checkpoint
+		sinks     []SinkResult
+		err       string
+	}{
+		{
+			name:      "Without a checkpoint",
+			delivered: []string{"event 0", "event 1", "event 2", "event 3", "event 4"},
+			want:      after(list[4]),
+		},
+		{
+			name:       "Resumes after the checkpointed event",
+			checkpoint: &resumed,
+			delivered:  []string{"event 2", "event 3", "event 4"},
+			want:       after(list[4]),
+		},
+		{
+			name:       "Continues from the next token of a truncated run",
+			checkpoint: &truncated,
+			delivered:  []string{"event 2", "event 3", "event 4"},
+			want:       after(list[4]),
+		},
+		{
+			name:       "Resumes after the checkpointed event when the next token expired",
+			checkpoint: &expired,
+			delivered:  []string{"event 2", "event 3", "event 4"},
+			want:       after(list[4]),
+		},
+		{
+			name:       "Keeps the checkpoint when a sink fails",
+			checkpoint: &truncated,
+			broken:     true,
+			delivered:  []string{"event 2", "event 3", "event 4"},
+			want:       truncated,
+			sinks: []SinkResult{
+				{Name: "archive", Delivered: 1},
+				{Name: "broken", Failed: 1},
+			},
+			err: `stream "fpm": failed to deliver log events, sink "broken"`,
+		},
+		{
+			name:       "Keeps the next token when continuing is throttled",
+			checkpoint: &truncated,
+			throttles:  1,
+			want:       truncated,
+			err:        "ThrottlingException",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, streaming := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s streaming=%t", tt.name, streaming), func(t *testing.T) {
+				fake, _ := newFake("event 0", "event 1", "event 2", "event 3", "event 4")
+				fake.Throttle(tt.throttles)
+
+				job := newJob("/skpr/test/things", t.TempDir())
+				job.Sinks[0].Name = "archive"
+
+				if tt.broken {
+					// Batches can't be written below a regular file.
+					path := filepath.Join(t.TempDir(), "broken")
+					assert.NoError(t, os.WriteFile(path, nil, 0o600))
+
+					job.Sinks = append(job.Sinks, util.Sink{Type: util.SinkFile, Name: "broken", Directory: path})
+				}
+
+				checkpoints := checkpoint.NewFileStore(t.TempDir())
+				key := checkpoint.Key(job.Name, job.GroupName, "fpm")
+
+				if tt.checkpoint != nil {
+					assert.NoError(t, checkpoints.Put(context.Background(), key, *tt.checkpoint))
+				}
+
+				params := newParams(t, job, checkpoints)
+				params.Streaming = streaming
+
+				result, err := Run(context.Background(), logger, Clients{CloudWatchLogs: fake}, params)
+				if tt.err != "" {
+					assert.ErrorContains(t, err, tt.err)
+					assert.Equal(t, tt.sinks, result.Sinks)
+				} else {
+					assert.NoError(t, err)
+				}
+
+				assert.Equal(t, tt.delivered, readDelivered(t, job.Sinks[0].Directory))
+
+				cp, err := checkpoints.Get(context.Background(), key)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, cp)
+			})
+		}
+	}
+}
+
+// Fake which takes until stopBefore to return the first page, so that runs stop after it.
+type slowFake struct {
+	*cloudwatchtest.Fake
+	stopBefore time.Time
+}
+
+func (f *slowFake) GetLogEvents(ctx context.Context, params *cloudwatchlogs.GetLogEventsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	time.Sleep(time.Until(f.stopBefore) + time.Millisecond)
+	return f.Fake.GetLogEvents(ctx, params, optFns...)
+}
+
+func TestRunTruncated(t *testing.T) {
+	fake, list := newFake("event 0", "event 1", "event 2", "event 3", "event 4")
+
+	job := newJob("/skpr/test/things", t.TempDir())
+	checkpoints := checkpoint.NewFileStore(t.TempDir())
+
+	params := newParams(t, job, checkpoints)
+	params.StopBefore = time.Now().Add(20 * time.Millisecond)
+
+	result, err := Run(context.Background(), logger, Clients{CloudWatchLogs: &slowFake{Fake: fake, stopBefore: params.StopBefore}}, params)
+	assert.NoError(t, err)
+	assert.True(t, result.Truncated)
+	assert.Equal(t, 2, result.Count)
+
+	cp, err := checkpoints.Get(context.Background(), checkpoint.Key(job.Name, job.GroupName, "fpm"))
+	assert.NoError(t, err)
+	assert.Equal(t, "f/2/0", cp.NextToken)
+
+	// The next scheduled run finishes the window of the truncated run before moving on.
+	params = newParams(t, job, checkpoints)
+	params.Start, params.End = start.Add(time.Hour), start.Add(2*time.Hour)
+
+	result, err = Run(context.Background(), logger, Clients{CloudWatchLogs: fake}, params)
+	assert.NoError(t, err)
+	assert.False(t, result.Truncated)
+	assert.Equal(t, 3, result.Count)
+
+	assert.Equal(t, []string{"event 0", "event 1", "event 2", "event 3", "event 4"}, readDelivered(t, job.Sinks[0].Directory))
+
+	cp, err = checkpoints.Get(context.Background(), checkpoint.Key(job.Name, job.GroupName, "fpm"))
+	assert.NoError(t, err)
+	assert.Equal(t, after(list[4]), cp)
+}
+
+func TestRunManifest(t *testing.T) {
+	fake, _ := newFake("event 0", "event 1", "event 2", "event 3", "event 4")
+
+	job := newJob("/skpr/test/things", t.TempDir())
+	job.Sinks[0].Name = "archive"
+	job.Manifest = aws.Bool(true)
+	job.MaxPartEvents = 2
+
+	_, err := Run(context.Background(), logger, Clients{CloudWatchLogs: fake}, newParams(t, job, nil))
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(job.Sinks[0].Directory, "manifests/skpr/test/things/20261016T100000Z.json"))
+	assert.NoError(t, err)
+
+	var m manifest.Manifest
+	assert.NoError(t, json.Unmarshal(data, &m))
+
+	var (
+		keys   []string
+		counts []int
+	)
+
+	for _, object := range m.Objects {
+		keys = append(keys, object.Key)
+		counts = append(counts, object.Count)
+		assert.Equal(t, []string{"archive"}, object.Sinks)
+	}
+
+	assert.Equal(t, []string{"fpm/20261016T100000Z.gz", "fpm/20261016T100000Z-0001.gz", "fpm/20261016T100000Z-0002.gz"}, keys)
+	assert.Equal(t, []int{2, 2, 1}, counts)
+}
+
+func TestRunManifestJobs(t *testing.T) {
+	fake, _ := newFake("event 0", "event 1")
+
+	directory := t.TempDir()
+
+	// Jobs on the same group and sink are told apart by their names.
+	for _, name := range []string{"app", "errors"} {
+		job := newJob(name, directory)
+		job.KeyTemplate = "{job}/{stream}/{end}.gz"
+		job.Manifest = aws.Bool(true)
+
+		_, err := Run(context.Background(), logger, Clients{CloudWatchLogs: fake}, newParams(t, job, nil))
+		assert.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(directory, "manifests", name, "20261016T100000Z.json"))
+		assert.NoError(t, err)
+
+		var m manifest.Manifest
+		assert.NoError(t, json.Unmarshal(data, &m))
+		assert.Equal(t, name, m.Job)
+		assert.Len(t, m.Objects, 1)
+		assert.Equal(t, name+"/fpm/20261016T100000Z.gz", m.Objects[0].Key)
+	}
+}
+
+func TestRunJobsShareGroup(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.Put("/skpr/test/things", "fpm",
+		cloudwatchtest.Event(start, "INFO started"),
+		cloudwatchtest.Event(start.Add(time.Minute), "ERROR cache down"),
+		cloudwatchtest.Event(start.Add(2*time.Minute), "INFO cache up"),
+	)
+
+	clients := Clients{CloudWatchLogs: fake}
+	checkpoints := checkpoint.NewFileStore(t.TempDir())
+
+	sentinel := newJob("sentinel", t.TempDir())
+	sentinel.FilterPattern = "ERROR"
+
+	archive := newJob("archive", t.TempDir())
+
+	for _, job := range []util.Job{sentinel, archive} {
+		_, err := Run(context.Background(), logger, clients, newParams(t, job, checkpoints))
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"ERROR cache down"}, readDelivered(t, sentinel.Sinks[0].Directory))
+
+	// The archive doesn't resume from the checkpoint of the sentinel job.
+	assert.Equal(t, []string{"INFO started", "ERROR cache down", "INFO cache up"}, readDelivered(t, archive.Sinks[0].Directory))
+
+	// Each job keeps its own checkpoint.
+	cp, err := checkpoints.Get(context.Background(), checkpoint.Key(sentinel.Name, sentinel.GroupName, "fpm"))
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(time.Minute).UnixMilli(), cp.Timestamp)
+
+	cp, err = checkpoints.Get(context.Background(), checkpoint.Key(archive.Name, archive.GroupName, "fpm"))
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(2*time.Minute).UnixMilli(), cp.Timestamp)
+}
+
+// Store which records the keys it was asked for.
+type recordingStore struct {
+	checkpoint.Store
+	gets []string
+}
+
+func (s *recordingStore) Get(ctx context.Context, key string) (checkpoint.Checkpoint, error) {
+	s.gets = append(s.gets, key)
+	return s.Store.Get(ctx, key)
+}
+
+func TestRunLookback(t *testing.T) {
+	fake := cloudwatchtest.New()
+	fake.Put("/skpr/test/things", "ecs/app/1", cloudwatchtest.Event(start.Add(-72*time.Hour), "INFO rotated out"))
+	fake.Put("/skpr/test/things", "ecs/app/2", cloudwatchtest.Event(start.Add(-2*time.Hour), "INFO behind"))
+	fake.Put("/skpr/test/things", "ecs/app/3", cloudwatchtest.Event(start.Add(time.Minute), "INFO started"))
+
+	job := newJob("/skpr/test/things", t.TempDir())
+	job.StreamName = ""
+	job.AllStreams = true
+
+	store := &recordingStore{Store: checkpoint.NewFileStore(t.TempDir())}
+
+	params := newParams(t, job, store)
+	params.Lookback = 24 * time.Hour
+
+	result, err := Run(context.Background(), logger, Clients{CloudWatchLogs: fake}, params)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Count)
+
+	// Streams without events since well before the window don't have their checkpoint checked.
+	assert.Equal(t, []string{"skpr/test/things/ecs/app/2", "skpr/test/things/ecs/app/3"}, store.gets)
+}
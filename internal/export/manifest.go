@@ -0,0 +1,129 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/key"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/manifest"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/sink"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/util"
+)
+
+// Adds the parts which were delivered to at least one sink to the manifest.
+func recordObjects(m *manifest.Manifest, parts []events.Part, batches []sink.Batch, results [][]sink.Result) {
+	if m == nil {
+		return
+	}
+
+	for i, part := range parts {
+		if i >= len(batches) || i >= len(results) {
+			break
+		}
+
+		var delivered []string
+
+		for _, result := range results[i] {
+			if result.Err == nil {
+				delivered = append(delivered, result.Sink)
+			}
+		}
+
+		if len(delivered) == 0 {
+			continue
+		}
+
+		m.Add(manifest.Object{
+			Key:            batches[i].Key,
+			GroupName:      batches[i].GroupName,
+			StreamName:     batches[i].StreamName,
+			Part:           part.Index,
+			Bytes:          part.CompressedBytes,
+			SHA256:         part.SHA256,
+			Count:          part.Count,
+			FirstTimestamp: time.UnixMilli(part.FirstTimestamp).UTC(),
+			LastTimestamp:  time.UnixMilli(part.LastTimestamp).UTC(),
+			Start:          batches[i].Start,
+			End:            batches[i].End,
+			Sinks:          delivered,
+		})
+	}
+}
+
+// Writes the manifest of a run to each sink which accepts manifests.
+func writeManifest(ctx context.Context, logger *slog.Logger, clients Clients, params Params, m *manifest.Manifest) error {
+	job := params.Job
+
+	name, err := key.Render(manifestKeyTemplate(job), key.Vars{
+		Job:     job.Name,
+		Group:   job.GroupName,
+		Account: clients.Account,
+		Region:  clients.Region,
+		RunID:   params.RunID,
+		Format:  format(job),
+		Start:   params.Start,
+		End:     params.End,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render manifest key, %w", err)
+	}
+
+	m.CreatedAt = time.Now().UTC()
+
+	data, err := m.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest, %w", err)
+	}
+
+	batch := sink.Batch{
+		Key:         name,
+		GroupName:   job.GroupName,
+		Start:       params.Start,
+		End:         params.End,
+		ContentType: manifest.ContentType,
+	}
+
+	var errs []error
+
+	for _, s := range newManifestSinks(job, clients) {
+		if err := s.Write(ctx, batch, bytes.NewReader(data)); err != nil {
+			logger.LogAttrs(ctx, slog.LevelError, "Failed to write manifest to sink",
+				slog.String(LogKeyJobName, job.Name),
+				slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+				slog.String(LogKeySinkName, s.Name()),
+				slog.String(LogKeySinkKey, name),
+				slog.String(LogKeyError, err.Error()))
+
+			errs = append(errs, fmt.Errorf("sink %q: %w", s.Name(), err))
+
+			continue
+		}
+
+		logger.LogAttrs(ctx, slog.LevelInfo, "Finished writing manifest to sink",
+			slog.String(LogKeyJobName, job.Name),
+			slog.String(LogKeyCloudWatchLogsGroupName, job.GroupName),
+			slog.String(LogKeySinkName, s.Name()),
+			slog.String(LogKeySinkKey, name),
+			slog.Int(LogKeyManifestObjectCount, len(m.Objects)))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to write manifest, %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Returns the template used to name the manifests of a job.
+func manifestKeyTemplate(job util.Job) string {
+	if job.ManifestKeyTemplate == "" {
+		return manifest.DefaultKeyTemplate
+	}
+
+	return job.ManifestKeyTemplate
+}
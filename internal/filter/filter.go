@@ -0,0 +1,211 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const (
+	// OpEqual keeps events whose field equals the value.
+	OpEqual = "=="
+	// OpNotEqual keeps events whose field is missing or doesn't equal the value.
+	OpNotEqual = "!="
+	// OpMatch keeps events whose field matches the regular expression.
+	OpMatch = "=~"
+	// OpNotMatch keeps events whose field is missing or doesn't match the regular expression.
+	OpNotMatch = "!~"
+)
+
+// Syntax of a field rule eg. `level != "debug"`.
+var fieldRuleRegex = regexp.MustCompile(`^\s*([^\s=!~]+)\s*(==|!=|=~|!~)\s*(.+?)\s*$`)
+
+// Config of the rules which decide the events to keep.
+type Config struct {
+	// Include keeps only the messages which match at least one regular expression. Everything is kept when empty.
+	Include []string
+	// Exclude drops the messages which match any regular expression eg. "GET /healthz".
+	Exclude []string
+	// Fields compares the fields of JSON messages eg. `level != "debug"`. Every rule must hold.
+	Fields []string
+}
+
+// Filter decides which events to keep.
+type Filter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	fields  []fieldRule
+}
+
+// Compares a field of JSON messages.
+type fieldRule struct {
+	path  []string
+	op    string
+	value any
+	regex *regexp.Regexp
+}
+
+// New compiles the rules. Returns nil when there are none, which keeps every event.
+func New(config Config) (*Filter, error) {
+	if len(config.Include) == 0 && len(config.Exclude) == 0 && len(config.Fields) == 0 {
+		return nil, nil
+	}
+
+	f := &Filter{}
+
+	for _, expr := range config.Include {
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", expr, err)
+		}
+
+		f.include = append(f.include, regex)
+	}
+
+	for _, expr := range config.Exclude {
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("exclude %q: %w", expr, err)
+		}
+
+		f.exclude = append(f.exclude, regex)
+	}
+
+	for _, rule := range config.Fields {
+		parsed, err := parseFieldRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", rule, err)
+		}
+
+		f.fields = append(f.fields, parsed)
+	}
+
+	return f, nil
+}
+
+// Parses a rule of the form "<path> <op> <value>". Values are JSON literals, and regular expressions may be quoted.
+func parseFieldRule(rule string) (fieldRule, error) {
+	match := fieldRuleRegex.FindStringSubmatch(rule)
+	if match == nil {
+		return fieldRule{}, fmt.Errorf("must be of the form <field> <op> <value> where op is one of %s, %s, %s or %s", OpEqual, OpNotEqual, OpMatch, OpNotMatch)
+	}
+
+	parsed := fieldRule{
+		path: strings.Split(match[1], "."),
+		op:   match[2],
+	}
+
+	switch parsed.op {
+	case OpEqual, OpNotEqual:
+		if err := json.Unmarshal([]byte(match[3]), &parsed.value); err != nil {
+			return parsed, fmt.Errorf(`value must be a JSON literal eg. "debug" or 500`)
+		}
+	case OpMatch, OpNotMatch:
+		expr := match[3]
+
+		if strings.HasPrefix(expr, `"`) {
+			if err := json.Unmarshal([]byte(expr), &expr); err != nil {
+				return parsed, fmt.Errorf("value must be a regular expression, %w", err)
+			}
+		}
+
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return parsed, err
+		}
+
+		parsed.regex = regex
+	}
+
+	return parsed, nil
+}
+
+// Keep reports whether an event with the message is kept. A nil filter keeps every event.
+func (f *Filter) Keep(message string) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.include) > 0 && !matchAny(f.include, message) {
+		return false
+	}
+
+	if matchAny(f.exclude, message) {
+		return false
+	}
+
+	if len(f.fields) == 0 {
+		return true
+	}
+
+	// Messages which aren't JSON objects don't have any fields.
+	var fields map[string]any
+	_ = json.Unmarshal([]byte(message), &fields)
+
+	for _, rule := range f.fields {
+		if !rule.holds(fields) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reports whether any of the regular expressions match.
+func matchAny(list []*regexp.Regexp, message string) bool {
+	for _, regex := range list {
+		if regex.MatchString(message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether the rule holds for the fields of a message.
+func (r fieldRule) holds(fields map[string]any) bool {
+	value, ok := lookup(fields, r.path)
+
+	switch r.op {
+	case OpEqual:
+		return ok && reflect.DeepEqual(value, r.value)
+	case OpNotEqual:
+		return !ok || !reflect.DeepEqual(value, r.value)
+	case OpMatch:
+		return ok && r.regex.MatchString(text(value))
+	default:
+		return !ok || !r.regex.MatchString(text(value))
+	}
+}
+
+// Returns the value at a path of nested objects eg. "http.status".
+func lookup(fields map[string]any, path []string) (any, bool) {
+	var value any = fields
+
+	for _, name := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// Returns a value as text for matching. Strings are matched without their quotes.
+func text(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	data, _ := json.Marshal(value)
+
+	return string(data)
+}
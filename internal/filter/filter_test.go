@@ -0,0 +1,134 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	f, err := New(Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+
+	// A nil filter keeps everything.
+	assert.True(t, f.Keep("GET /healthz"))
+
+	for _, config := range []Config{
+		{Include: []string{"("}},
+		{Exclude: []string{"[a-"}},
+		{Fields: []string{"level"}},
+		{Fields: []string{"level == debug"}},
+		{Fields: []string{"level =~ ("}},
+		{Fields: []string{`level =~ "\q"`}},
+	} {
+		_, err := New(config)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestKeep(t *testing.T) {
+	var tests = []struct {
+		name    string
+		config  Config
+		message string
+		keep    bool
+	}{
+		{
+			name:    "Included",
+			config:  Config{Include: []string{"ERROR", "WARN"}},
+			message: "WARN disk is nearly full",
+			keep:    true,
+		},
+		{
+			name:    "Not included",
+			config:  Config{Include: []string{"ERROR", "WARN"}},
+			message: "INFO started",
+		},
+		{
+			name:    "Excluded",
+			config:  Config{Exclude: []string{`^GET /healthz\b`}},
+			message: "GET /healthz 200",
+		},
+		{
+			name:    "Included and excluded",
+			config:  Config{Include: []string{"GET"}, Exclude: []string{"/healthz"}},
+			message: "GET /healthz 200",
+		},
+		{
+			name:    "Not excluded",
+			config:  Config{Exclude: []string{`^GET /healthz\b`}},
+			message: "GET /node/1 200",
+			keep:    true,
+		},
+		{
+			name:    "Field not equal",
+			config:  Config{Fields: []string{`level != "debug"`}},
+			message: `{"level":"info","msg":"started"}`,
+			keep:    true,
+		},
+		{
+			name:    "Field equal",
+			config:  Config{Fields: []string{`level != "debug"`}},
+			message: `{"level":"debug","msg":"started"}`,
+		},
+		{
+			name:    "Nested field",
+			config:  Config{Fields: []string{"http.status == 500"}},
+			message: `{"http":{"status":500}}`,
+			keep:    true,
+		},
+		{
+			name:    "Field of another type",
+			config:  Config{Fields: []string{"http.status == 500"}},
+			message: `{"http":{"status":"500"}}`,
+		},
+		{
+			name:    "Missing field",
+			config:  Config{Fields: []string{`level == "error"`}},
+			message: `{"msg":"started"}`,
+		},
+		{
+			name:    "Missing field not equal",
+			config:  Config{Fields: []string{`level != "debug"`}},
+			message: `{"msg":"started"}`,
+			keep:    true,
+		},
+		{
+			name:    "Not JSON",
+			config:  Config{Fields: []string{`level != "debug"`}},
+			message: "debug started",
+			keep:    true,
+		},
+		{
+			name:    "Field matches",
+			config:  Config{Fields: []string{`path =~ "^/admin"`}},
+			message: `{"path":"/admin/config"}`,
+			keep:    true,
+		},
+		{
+			name:    "Field doesn't match",
+			config:  Config{Fields: []string{"path !~ ^/healthz"}},
+			message: `{"path":"/healthz"}`,
+		},
+		{
+			name:    "Number matches",
+			config:  Config{Fields: []string{"status =~ ^5"}},
+			message: `{"status":503}`,
+			keep:    true,
+		},
+		{
+			name:    "Every field rule holds",
+			config:  Config{Fields: []string{`level != "debug"`, "status =~ ^5"}},
+			message: `{"level":"info","status":200}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.config)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.keep, f.Keep(tt.message))
+		})
+	}
+}
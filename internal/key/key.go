@@ -0,0 +1,130 @@
+package key
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultTemplate used to build keys unless configured otherwise.
+const DefaultTemplate = "{stream}/{end}.gz"
+
+// TimeFormat used by the start and end variables.
+const TimeFormat = "20060102T150405Z"
+
+// Vars available to a template.
+type Vars struct {
+	// Job which exported the batch. Defaults to the log group.
+	Job     string
+	Group   string
+	Stream  string
+	Account string
+	Region  string
+	// RunID identifies the run. Retries of the same run share an ID.
+	RunID string
+	// Format the batch was packaged in eg. "csv".
+	Format string
+	// Start and End of the window being exported. Date partitions are taken from Start.
+	Start time.Time
+	End   time.Time
+	// Part of the package, numbered from 0.
+	Part int
+}
+
+// Names of the variables available to a template.
+var names = []string{"job", "group", "stream", "account", "region", "run_id", "format", "start", "end", "year", "month", "day", "hour", "part"}
+
+// Returns the value of each variable.
+func (v Vars) values() map[string]string {
+	start := v.Start.UTC()
+
+	return map[string]string{
+		// Groups usually start with a slash which would leave an empty path segment, as do jobs named after them.
+		"job":     strings.Trim(v.Job, "/"),
+		"group":   strings.Trim(v.Group, "/"),
+		"stream":  v.Stream,
+		"account": v.Account,
+		"region":  v.Region,
+		"run_id":  v.RunID,
+		"format":  v.Format,
+		"start":   start.Format(TimeFormat),
+		"end":     v.End.UTC().Format(TimeFormat),
+		"year":    start.Format("2006"),
+		"month":   start.Format("01"),
+		"day":     start.Format("02"),
+		"hour":    start.Format("15"),
+		"part":    fmt.Sprintf("%04d", v.Part),
+	}
+}
+
+// Check returns an error if the template is malformed or uses an unknown variable.
+func Check(template string) error {
+	_, err := render(template, nil)
+	return err
+}
+
+// Render replaces the {variables} of a template. The result never starts with a slash.
+// When the template doesn't use {part}, parts after the first are suffixed with their number.
+func Render(template string, vars Vars) (string, error) {
+	name, err := render(template, vars.values())
+	if err != nil {
+		return "", err
+	}
+
+	if vars.Part == 0 || strings.Contains(template, "{part}") {
+		return name, nil
+	}
+
+	return withSuffix(name, fmt.Sprintf("-%04d", vars.Part)), nil
+}
+
+// Inserts a suffix before the extensions of a name eg. "a/b.csv.gz" becomes "a/b-0001.csv.gz".
+func withSuffix(name, suffix string) string {
+	dir, base := path.Split(name)
+
+	if i := strings.Index(base, "."); i > 0 {
+		return dir + base[:i] + suffix + base[i:]
+	}
+
+	return name + suffix
+}
+
+// Renders a template, or only checks it when values is nil.
+func render(template string, values map[string]string) (string, error) {
+	var b strings.Builder
+
+	rest := template
+
+	for {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			b.WriteString(rest)
+			break
+		}
+
+		if rest[open] == '}' {
+			return "", fmt.Errorf("unexpected } at %d", len(template)-len(rest)+open)
+		}
+
+		b.WriteString(rest[:open])
+		rest = rest[open+1:]
+
+		end := strings.IndexAny(rest, "{}")
+		if end < 0 || rest[end] == '{' {
+			return "", fmt.Errorf("unclosed { at %d", len(template)-len(rest)-1)
+		}
+
+		name := rest[:end]
+		rest = rest[end+1:]
+
+		if !slices.Contains(names, name) {
+			return "", fmt.Errorf("unknown variable %q", name)
+		}
+
+		b.WriteString(values[name])
+	}
+
+	return strings.TrimPrefix(path.Clean("/"+b.String()), "/"), nil
+}
@@ -0,0 +1,89 @@
+package key
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	vars := Vars{
+		Job:     "app",
+		Group:   "/skpr/test/things",
+		Stream:  "ecs/app/123",
+		Account: "123456789012",
+		Region:  "ap-southeast-2",
+		RunID:   "4f7b1c2e",
+		Format:  "csv",
+		Start:   time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		End:     time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+	}
+
+	for _, tc := range []struct {
+		template string
+		want     string
+	}{
+		{
+			template: DefaultTemplate,
+			want:     "ecs/app/123/20261016T100000Z.gz",
+		},
+		{
+			template: "{group}/year={year}/month={month}/day={day}/hour={hour}/{stream}.{format}.gz",
+			want:     "skpr/test/things/year=2026/month=10/day=16/hour=09/ecs/app/123.csv.gz",
+		},
+		{
+			template: "/AWSLogs/{account}/{region}/{start}-{end}-{run_id}.gz",
+			want:     "AWSLogs/123456789012/ap-southeast-2/20261016T090000Z-20261016T100000Z-4f7b1c2e.gz",
+		},
+		{
+			template: "manifests/{job}/{end}.json",
+			want:     "manifests/app/20261016T100000Z.json",
+		},
+		{
+			template: "{account}//{stream}",
+			want:     "123456789012/ecs/app/123",
+		},
+	} {
+		t.Run(tc.template, func(t *testing.T) {
+			got, err := Render(tc.template, vars)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRenderParts(t *testing.T) {
+	vars := Vars{
+		Stream: "fpm",
+		End:    time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+		Part:   2,
+	}
+
+	got, err := Render(DefaultTemplate, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "fpm/20261016T100000Z-0002.gz", got)
+
+	got, err = Render("{stream}/{end}/{part}.csv.gz", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "fpm/20261016T100000Z/0002.csv.gz", got)
+
+	got, err = Render("{stream}", vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "fpm-0002", got)
+
+	vars.Part = 0
+
+	got, err = Render(DefaultTemplate, vars)
+	assert.NoError(t, err)
+	assert.Equal(t, "fpm/20261016T100000Z.gz", got)
+}
+
+func TestCheck(t *testing.T) {
+	assert.NoError(t, Check(DefaultTemplate))
+	assert.NoError(t, Check("static.gz"))
+	assert.EqualError(t, Check("{stream}/{minute}.gz"), `unknown variable "minute"`)
+	assert.EqualError(t, Check("{stream/{end}.gz"), "unclosed { at 0")
+	assert.EqualError(t, Check("{stream}}.gz"), "unexpected } at 8")
+	assert.EqualError(t, Check("{stream}/{end"), "unclosed { at 9")
+}
@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DefaultKeyTemplate names the manifest of a run when a job doesn't set one.
+const DefaultKeyTemplate = "manifests/{job}/{end}.json"
+
+// ContentType of manifests.
+const ContentType = "application/json"
+
+// Manifest lists the objects produced by a run, so that exports can be audited and new objects
+// discovered without listing the bucket.
+type Manifest struct {
+	Job       string `json:"job"`
+	GroupName string `json:"group_name"`
+	RunID     string `json:"run_id,omitempty"`
+	// Start and End of the window which was requested.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Truncated is set when the run stopped early and left events for the next run.
+	Truncated bool      `json:"truncated,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Objects   []Object  `json:"objects"`
+}
+
+// Object produced by a run.
+type Object struct {
+	// Key of the object relative to the prefix of each sink.
+	Key        string `json:"key"`
+	GroupName  string `json:"group_name"`
+	StreamName string `json:"stream_name"`
+	Part       int    `json:"part"`
+	// Bytes and SHA256 of the object as stored ie. compressed.
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+	Count  int    `json:"count"`
+	// FirstTimestamp and LastTimestamp of the events in the object.
+	FirstTimestamp time.Time `json:"first_timestamp"`
+	LastTimestamp  time.Time `json:"last_timestamp"`
+	// Start and End of the window the events were exported from. Later than the window of the run when
+	// the stream resumed from a checkpoint.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Sinks the object was delivered to.
+	Sinks []string `json:"sinks"`
+}
+
+// Add an object to the manifest.
+func (m *Manifest) Add(object Object) {
+	m.Objects = append(m.Objects, object)
+}
+
+// Marshal the manifest as indented JSON.
+func (m Manifest) Marshal() ([]byte, error) {
+	// Runs without events still list their objects as an empty array.
+	if m.Objects == nil {
+		m.Objects = []Object{}
+	}
+
+	return json.MarshalIndent(m, "", "  ")
+}
@@ -0,0 +1,47 @@
+package manifest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshal(t *testing.T) {
+	m := Manifest{
+		Job:       "app",
+		GroupName: "/skpr/test/app",
+		RunID:     "7bf73129-1428-4cd3-a780-95db273d1602",
+		Start:     time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		End:       time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2026, 10, 16, 10, 0, 42, 0, time.UTC),
+	}
+
+	m.Add(Object{
+		Key:            "fpm/20261016T100000Z.gz",
+		GroupName:      "/skpr/test/app",
+		StreamName:     "fpm",
+		Bytes:          1024,
+		SHA256:         "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Count:          3,
+		FirstTimestamp: time.Date(2026, 10, 16, 9, 1, 0, 0, time.UTC),
+		LastTimestamp:  time.Date(2026, 10, 16, 9, 59, 0, 0, time.UTC),
+		Start:          time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		End:            time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+		Sinks:          []string{"s3://skpr-test/my/test/prefix"},
+	})
+
+	data, err := m.Marshal()
+	assert.NoError(t, err)
+
+	expected, err := os.ReadFile("testdata/manifest.json")
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(data))
+}
+
+func TestMarshalEmpty(t *testing.T) {
+	data, err := Manifest{Job: "app"}.Marshal()
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"objects": []`)
+}
@@ -0,0 +1,336 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// DetectorEmail detects email addresses.
+	DetectorEmail = "email"
+	// DetectorIPv4 detects IPv4 addresses.
+	DetectorIPv4 = "ipv4"
+	// DetectorIPv6 detects IPv6 addresses.
+	DetectorIPv6 = "ipv6"
+	// DetectorJWT detects JSON web tokens eg. in bearer authorization headers.
+	DetectorJWT = "jwt"
+	// DetectorAWSAccessKey detects the IDs of AWS access keys.
+	DetectorAWSAccessKey = "aws_access_key"
+	// DetectorPAN detects payment card numbers of known issuers which pass the Luhn check.
+	DetectorPAN = "pan"
+)
+
+const (
+	// ActionMask replaces matches with the name of the rule eg. "[REDACTED:email]".
+	ActionMask = "mask"
+	// ActionHash replaces matches with an HMAC of their value, so that they can still be correlated.
+	ActionHash = "hash"
+	// ActionDrop drops events with a match.
+	ActionDrop = "drop"
+)
+
+// Detectors lists the built-in detectors.
+var Detectors = []string{DetectorEmail, DetectorIPv4, DetectorIPv6, DetectorJWT, DetectorAWSAccessKey, DetectorPAN}
+
+// Expressions of the built-in detectors. Candidates are checked by validators where an expression alone is too loose.
+var detectors = map[string]struct {
+	regex *regexp.Regexp
+	valid validator
+}{
+	DetectorEmail: {
+		regex: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	DetectorIPv4: {
+		regex: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+	},
+	DetectorIPv6: {
+		regex: regexp.MustCompile(`(?i)(?:[0-9a-f]{1,4})?(?::[0-9a-f]{0,4}){2,7}`),
+		valid: validIPv6,
+	},
+	DetectorJWT: {
+		regex: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`),
+	},
+	DetectorAWSAccessKey: {
+		regex: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`),
+	},
+	DetectorPAN: {
+		regex: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		valid: validPAN,
+	},
+}
+
+// Reports whether the match of a detector at message[start:end] should be redacted.
+type validator func(message string, start, end int) bool
+
+// Rule redacts the matches of a detector or regular expression.
+type Rule struct {
+	// Name the hits of the rule are counted under. Defaults to Detector.
+	Name string
+	// Detector is one of Detectors. Either Detector or Pattern must be set.
+	Detector string
+	// Pattern is a regular expression.
+	Pattern string
+	// Action is one of ActionMask, ActionHash or ActionDrop. Defaults to ActionMask.
+	Action string
+}
+
+// Config of a Redactor.
+type Config struct {
+	// Rules applied to each message, in order.
+	Rules []Rule
+	// HashKey used to HMAC matches. Required by ActionHash.
+	HashKey string
+}
+
+// Redactor removes sensitive values from messages. Safe for concurrent use.
+type Redactor struct {
+	rules []rule
+	key   []byte
+}
+
+// A compiled rule.
+type rule struct {
+	name   string
+	action string
+	regex  *regexp.Regexp
+	valid  validator
+}
+
+// New compiles the rules. Returns nil when there are none, which leaves every message as is.
+func New(config Config) (*Redactor, error) {
+	if len(config.Rules) == 0 {
+		return nil, nil
+	}
+
+	r := &Redactor{
+		key: []byte(config.HashKey),
+	}
+
+	names := make(map[string]bool)
+
+	for i, cfg := range config.Rules {
+		compiled, err := compile(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+
+		if names[compiled.name] {
+			return nil, fmt.Errorf("rules[%d]: name %q must be unique", i, compiled.name)
+		}
+
+		names[compiled.name] = true
+
+		if compiled.action == ActionHash && config.HashKey == "" {
+			return nil, fmt.Errorf("rules[%d]: hash key is required by the %q action", i, ActionHash)
+		}
+
+		r.rules = append(r.rules, compiled)
+	}
+
+	return r, nil
+}
+
+// Compiles a single rule.
+func compile(cfg Rule) (rule, error) {
+	compiled := rule{
+		name:   cfg.Name,
+		action: cfg.Action,
+	}
+
+	if compiled.action == "" {
+		compiled.action = ActionMask
+	}
+
+	switch compiled.action {
+	case ActionMask, ActionHash, ActionDrop:
+	default:
+		return compiled, fmt.Errorf("action must be one of %q, %q or %q", ActionMask, ActionHash, ActionDrop)
+	}
+
+	switch {
+	case cfg.Detector != "" && cfg.Pattern != "":
+		return compiled, errors.New("detector and pattern must not both be set")
+	case cfg.Detector != "":
+		detector, ok := detectors[cfg.Detector]
+		if !ok {
+			return compiled, fmt.Errorf("detector must be one of %s", strings.Join(Detectors, ", "))
+		}
+
+		compiled.regex = detector.regex
+		compiled.valid = detector.valid
+
+		if compiled.name == "" {
+			compiled.name = cfg.Detector
+		}
+	case cfg.Pattern != "":
+		regex, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return compiled, fmt.Errorf("pattern is invalid: %w", err)
+		}
+
+		compiled.regex = regex
+
+		if compiled.name == "" {
+			return compiled, errors.New("name is required for a pattern")
+		}
+	default:
+		return compiled, errors.New("detector or pattern is required")
+	}
+
+	return compiled, nil
+}
+
+// Redact returns the message with the matches of each rule replaced, and adds the hits of each rule to hits.
+// Returns false when a rule with ActionDrop matched, in which case the event should be dropped.
+// A nil Redactor returns the message as is.
+func (r *Redactor) Redact(message string, hits map[string]int) (string, bool) {
+	if r == nil {
+		return message, true
+	}
+
+	for _, rule := range r.rules {
+		var (
+			redacted strings.Builder
+			count    int
+			last     int
+		)
+
+		for _, match := range rule.regex.FindAllStringIndex(message, -1) {
+			start, end := match[0], match[1]
+
+			if rule.valid != nil && !rule.valid(message, start, end) {
+				continue
+			}
+
+			count++
+
+			redacted.WriteString(message[last:start])
+			redacted.WriteString(r.replace(rule, message[start:end]))
+			last = end
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		hits[rule.name] += count
+
+		if rule.action == ActionDrop {
+			return "", false
+		}
+
+		redacted.WriteString(message[last:])
+		message = redacted.String()
+	}
+
+	return message, true
+}
+
+// Returns the replacement of a match.
+func (r *Redactor) replace(rule rule, match string) string {
+	if rule.action == ActionHash {
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte(match))
+
+		return fmt.Sprintf("[HASH:%s:%s]", rule.name, hex.EncodeToString(mac.Sum(nil))[:16])
+	}
+
+	return fmt.Sprintf("[REDACTED:%s]", rule.name)
+}
+
+// Reports whether a candidate is an IPv6 address. The expression also matches eg. times like "09:00:00" and
+// parts of words like "Cache::add", so candidates must stand on their own.
+func validIPv6(message string, start, end int) bool {
+	if start > 0 && (isWordByte(message[start-1]) || message[start-1] == '.') {
+		return false
+	}
+
+	// Trailing dots may end a sentence, unless they continue eg. an IPv4-mapped address.
+	if end < len(message) && isWordByte(message[end]) || end+1 < len(message) && message[end] == '.' && isWordByte(message[end+1]) {
+		return false
+	}
+
+	candidate := message[start:end]
+
+	if strings.Trim(candidate, ":") == "" {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(candidate)
+
+	return err == nil && addr.Is6()
+}
+
+// Reports whether a byte can be part of a word or an address.
+func isWordByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':'
+}
+
+// Issuer prefixes of payment cards and the lengths of their numbers. Other runs of digits, such as timestamps and IDs,
+// pass the Luhn check too often to be redacted on it alone.
+var issuers = []struct {
+	low, high string
+	lengths   []int
+}{
+	// Visa.
+	{low: "4", high: "4", lengths: []int{13, 16, 19}},
+	// Mastercard.
+	{low: "51", high: "55", lengths: []int{16}},
+	{low: "2221", high: "2720", lengths: []int{16}},
+	// American Express.
+	{low: "34", high: "34", lengths: []int{15}},
+	{low: "37", high: "37", lengths: []int{15}},
+	// Discover.
+	{low: "6011", high: "6011", lengths: []int{16, 17, 18, 19}},
+	{low: "65", high: "65", lengths: []int{16, 17, 18, 19}},
+}
+
+// Reports whether a candidate is the number of a known card issuer which passes the Luhn check.
+func validPAN(message string, start, end int) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+
+		return r
+	}, message[start:end])
+
+	for _, issuer := range issuers {
+		prefix := digits[:min(len(issuer.low), len(digits))]
+
+		if prefix >= issuer.low && prefix <= issuer.high && slices.Contains(issuer.lengths, len(digits)) {
+			return luhn(digits)
+		}
+	}
+
+	return false
+}
+
+// Reports whether digits pass the Luhn check.
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+		double = !double
+	}
+
+	return sum%10 == 0
+}
@@ -0,0 +1,279 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+)
+
+const (
+	// AzureAuthorityHost used to request tokens unless configured otherwise.
+	AzureAuthorityHost = "https://login.microsoftonline.com"
+	// AzureMaxRequestBytes is the largest request accepted by the Logs Ingestion API.
+	// https://learn.microsoft.com/azure/azure-monitor/service-limits#logs-ingestion-api
+	AzureMaxRequestBytes = 1024 * 1024
+	// AzureMaxAttempts is the number of times a throttled request is sent before giving up.
+	AzureMaxAttempts = 5
+
+	azureScope      = "https://monitor.azure.com/.default"
+	azureAPIVersion = "2023-01-01"
+	// Tokens are refreshed this long before they expire.
+	azureTokenExpiryMargin = 5 * time.Minute
+)
+
+// AzureConfig for a sink which posts to the Azure Monitor Logs Ingestion API.
+type AzureConfig struct {
+	// Endpoint is the logs ingestion endpoint of the Data Collection Endpoint.
+	Endpoint string
+	// RuleID is the immutable ID of the Data Collection Rule.
+	RuleID string
+	// StreamName declared by the Data Collection Rule eg. "Custom-CloudWatchLogs".
+	StreamName string
+	// TenantID, ClientID and ClientSecret of the app registration used to authenticate.
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// AuthorityHost issues tokens. Defaults to AzureAuthorityHost.
+	AuthorityHost string
+	// Client used to send requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Azure posts batches to a Data Collection Rule using the Logs Ingestion API.
+type Azure struct {
+	config AzureConfig
+	// Largest request body to send. Lowered in tests.
+	maxBytes int
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Record sent to the Data Collection Rule. The stream declared by the rule needs matching columns.
+type azureRecord struct {
+	TimeGenerated string `json:"TimeGenerated"`
+	RawData       string `json:"RawData"`
+	LogGroup      string `json:"LogGroup"`
+	LogStream     string `json:"LogStream"`
+}
+
+// NewAzure returns a sink which posts batches to the Logs Ingestion API.
+func NewAzure(config AzureConfig) *Azure {
+	if config.AuthorityHost == "" {
+		config.AuthorityHost = AzureAuthorityHost
+	}
+
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	return &Azure{
+		config:   config,
+		maxBytes: AzureMaxRequestBytes,
+	}
+}
+
+// Name of the sink.
+func (s *Azure) Name() string {
+	return fmt.Sprintf("%s/dataCollectionRules/%s/streams/%s", strings.TrimSuffix(s.config.Endpoint, "/"), s.config.RuleID, s.config.StreamName)
+}
+
+// Write decodes the events of a batch and posts them in requests no larger than the API limit.
+// Packages which don't carry the timestamp and message of each event are rejected rather than sent empty.
+func (s *Azure) Write(ctx context.Context, batch Batch, body io.Reader) error {
+	for _, column := range []string{events.ColumnTimestamp, events.ColumnMessage} {
+		if !events.Carries(batch.Format, batch.Columns, column) {
+			return fmt.Errorf("package does not carry the %q column", column)
+		}
+	}
+
+	zipReader, err := gzip.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip reader, %w", err)
+	}
+
+	decoder, err := events.NewDecoder(batch.Format, batch.Columns, zipReader)
+	if err != nil {
+		return err
+	}
+
+	var (
+		buf   bytes.Buffer
+		count int
+	)
+
+	for {
+		event, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to decode log event, %w", err)
+		}
+
+		record, err := json.Marshal(azureRecord{
+			TimeGenerated: event.Timestamp,
+			RawData:       event.Message,
+			LogGroup:      batch.GroupName,
+			LogStream:     batch.StreamName,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal log event, %w", err)
+		}
+
+		// Brackets and a separator are added around each record.
+		if len(record)+2 > s.maxBytes {
+			return fmt.Errorf("log event of %d bytes exceeds the request limit of %d bytes", len(record), s.maxBytes)
+		}
+
+		if count > 0 && buf.Len()+len(record)+2 > s.maxBytes {
+			if err := s.send(ctx, &buf); err != nil {
+				return err
+			}
+
+			count = 0
+		}
+
+		if count == 0 {
+			buf.WriteByte('[')
+		} else {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(record)
+		count++
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	return s.send(ctx, &buf)
+}
+
+// Closes the array in the buffer and posts it, retrying when throttled. The buffer is reset.
+func (s *Azure) send(ctx context.Context, buf *bytes.Buffer) error {
+	buf.WriteByte(']')
+	defer buf.Reset()
+
+	endpoint := fmt.Sprintf("%s?api-version=%s", s.Name(), azureAPIVersion)
+
+	for attempt := 1; ; attempt++ {
+		token, err := s.getToken(ctx)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			return fmt.Errorf("failed to build request, %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := s.config.Client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to send logs, %w", err)
+		}
+
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+
+		if resp.StatusCode < 300 {
+			return nil
+		}
+
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+			return fmt.Errorf("failed to send logs, status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+		}
+
+		if attempt == AzureMaxAttempts {
+			return fmt.Errorf("failed to send logs after %d attempts, status %d", attempt, resp.StatusCode)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter(resp.Header.Get("Retry-After"), attempt)):
+		}
+	}
+}
+
+// Returns how long to wait before retrying a throttled request.
+func retryAfter(header string, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return time.Duration(attempt) * time.Second
+}
+
+// Returns a cached token or requests a new one using the client credentials flow.
+func (s *Azure) getToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Before(s.expires) {
+		return s.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.config.ClientID},
+		"client_secret": {s.config.ClientSecret},
+		"scope":         {azureScope},
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(s.config.AuthorityHost, "/"), s.config.TenantID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to build token request, %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to request token, status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode token, %w", err)
+	}
+
+	s.token = token.AccessToken
+	s.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - azureTokenExpiryMargin)
+
+	return s.token, nil
+}
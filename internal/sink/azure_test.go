@@ -0,0 +1,209 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+)
+
+// Stands in for Entra ID and the Logs Ingestion API.
+type fakeAzure struct {
+	mu sync.Mutex
+	// Tokens issued.
+	tokens int
+	// Requests to throttle before accepting logs.
+	throttle int
+	// Records accepted by each request.
+	requests [][]azureRecord
+}
+
+func (f *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/tenant/oauth2/v2.0/token":
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_secret") != "secret" {
+			http.Error(w, "invalid client", http.StatusUnauthorized)
+			return
+		}
+
+		f.tokens++
+
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3600, "token_type": "Bearer"}`, f.tokens)
+	case "/dataCollectionRules/dcr-1234/streams/Custom-CloudWatchLogs":
+		if r.Header.Get("Authorization") != "Bearer token-1" || r.URL.Query().Get("api-version") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if f.throttle > 0 {
+			f.throttle--
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		var records []azureRecord
+
+		if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f.requests = append(f.requests, records)
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Packages events the same way as events.Package.
+func packageCSV(t *testing.T, rows [][]string) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	zipWriter := gzip.NewWriter(&buf)
+	csvWriter := csv.NewWriter(zipWriter)
+	csvWriter.Comma = ' '
+
+	assert.NoError(t, csvWriter.WriteAll(rows))
+	assert.NoError(t, zipWriter.Close())
+
+	return &buf
+}
+
+func newTestAzure(server *httptest.Server, secret string) *Azure {
+	return NewAzure(AzureConfig{
+		Endpoint:      server.URL,
+		RuleID:        "dcr-1234",
+		StreamName:    "Custom-CloudWatchLogs",
+		TenantID:      "tenant",
+		ClientID:      "client",
+		ClientSecret:  secret,
+		AuthorityHost: server.URL,
+		Client:        server.Client(),
+	})
+}
+
+func TestAzure(t *testing.T) {
+	fake := &fakeAzure{throttle: 1}
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := newTestAzure(server, "secret")
+
+	// Small enough that each request only fits two records.
+	s.maxBytes = 300
+
+	rows := [][]string{
+		{"2026-10-16T00:00:00.000Z", `{"level": "info", "msg": "first"}`},
+		{"2026-10-16T00:00:01.000Z", "second with spaces"},
+		{"2026-10-16T00:00:02.000Z", "third"},
+	}
+
+	err := s.Write(context.Background(), batch, packageCSV(t, rows))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, fake.tokens)
+	assert.Equal(t, [][]azureRecord{
+		{
+			{TimeGenerated: "2026-10-16T00:00:00.000Z", RawData: `{"level": "info", "msg": "first"}`, LogGroup: batch.GroupName, LogStream: batch.StreamName},
+			{TimeGenerated: "2026-10-16T00:00:01.000Z", RawData: "second with spaces", LogGroup: batch.GroupName, LogStream: batch.StreamName},
+		},
+		{
+			{TimeGenerated: "2026-10-16T00:00:02.000Z", RawData: "third", LogGroup: batch.GroupName, LogStream: batch.StreamName},
+		},
+	}, fake.requests)
+}
+
+func TestAzureThrottled(t *testing.T) {
+	fake := &fakeAzure{throttle: AzureMaxAttempts}
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	err := newTestAzure(server, "secret").Write(context.Background(), batch, packageCSV(t, [][]string{
+		{"2026-10-16T00:00:00.000Z", "first"},
+	}))
+	assert.ErrorContains(t, err, "after 5 attempts")
+	assert.Empty(t, fake.requests)
+}
+
+func TestAzureUnauthorized(t *testing.T) {
+	server := httptest.NewServer(&fakeAzure{})
+	defer server.Close()
+
+	err := newTestAzure(server, "wrong").Write(context.Background(), batch, packageCSV(t, [][]string{
+		{"2026-10-16T00:00:00.000Z", "first"},
+	}))
+	assert.ErrorContains(t, err, "failed to request token, status 401")
+}
+
+func TestAzureEventTooLarge(t *testing.T) {
+	server := httptest.NewServer(&fakeAzure{})
+	defer server.Close()
+
+	s := newTestAzure(server, "secret")
+	s.maxBytes = 100
+
+	err := s.Write(context.Background(), batch, packageCSV(t, [][]string{
+		{"2026-10-16T00:00:00.000Z", strings.Repeat("a", 100)},
+	}))
+	assert.ErrorContains(t, err, "exceeds the request limit")
+}
+
+func TestAzureColumns(t *testing.T) {
+	fake := &fakeAzure{}
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ids := batch
+	ids.Columns = []string{events.ColumnGroup, events.ColumnStream, events.ColumnEventID}
+
+	err := newTestAzure(server, "secret").Write(context.Background(), ids, packageCSV(t, [][]string{
+		{"/skpr/test/things", "fpm", "2f1c"},
+	}))
+	assert.EqualError(t, err, `package does not carry the "timestamp" column`)
+	assert.Empty(t, fake.requests)
+}
+
+func TestAzureJSONL(t *testing.T) {
+	fake := &fakeAzure{}
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var buf bytes.Buffer
+
+	zipWriter := gzip.NewWriter(&buf)
+	encoder, err := events.NewEncoder(events.FormatJSONL, nil, zipWriter)
+	assert.NoError(t, err)
+	assert.NoError(t, encoder.Encode(events.Event{Timestamp: "2026-10-16T00:00:00.000Z", Message: `{"msg": "first"}`}))
+	assert.NoError(t, zipWriter.Close())
+
+	jsonl := batch
+	jsonl.Format = events.FormatJSONL
+
+	err = newTestAzure(server, "secret").Write(context.Background(), jsonl, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]azureRecord{
+		{
+			{TimeGenerated: "2026-10-16T00:00:00.000Z", RawData: `{"msg": "first"}`, LogGroup: batch.GroupName, LogStream: batch.StreamName},
+		},
+	}, fake.requests)
+}
@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Fanout writes a single body to several sinks at once. A sink which fails is dropped
+// without interrupting the others.
+type Fanout struct {
+	pipes []*pipe
+}
+
+// A sink reading from its end of a pipe.
+type pipe struct {
+	sink   Sink
+	writer *io.PipeWriter
+	done   chan error
+	// Set when the sink stopped reading.
+	err error
+}
+
+// NewFanout starts writing a batch to each of the sinks.
+func NewFanout(ctx context.Context, sinks []Sink, batch Batch) *Fanout {
+	f := &Fanout{}
+
+	for _, s := range sinks {
+		reader, writer := io.Pipe()
+
+		p := &pipe{
+			sink:   s,
+			writer: writer,
+			done:   make(chan error, 1),
+		}
+
+		go func() {
+			err := p.sink.Write(ctx, batch, reader)
+
+			// Unblocks the writer if the sink failed part way through.
+			reader.CloseWithError(err)
+
+			p.done <- err
+		}()
+
+		f.pipes = append(f.pipes, p)
+	}
+
+	return f
+}
+
+// Write to every sink which hasn't failed. Fails once every sink has.
+func (f *Fanout) Write(b []byte) (int, error) {
+	var (
+		alive int
+		errs  []error
+	)
+
+	for _, p := range f.pipes {
+		if p.err == nil {
+			if _, err := p.writer.Write(b); err != nil {
+				p.err = err
+			}
+		}
+
+		if p.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.sink.Name(), p.err))
+			continue
+		}
+
+		alive++
+	}
+
+	if alive == 0 {
+		return 0, errors.Join(errs...)
+	}
+
+	return len(b), nil
+}
+
+// Close completes the batch and waits for every sink to finish. Fails only if every sink failed,
+// see Results for each sink.
+func (f *Fanout) Close() error {
+	var errs []error
+
+	for _, p := range f.pipes {
+		p.writer.Close()
+
+		if err := <-p.done; err != nil {
+			p.err = err
+		}
+
+		if p.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.sink.Name(), p.err))
+		}
+	}
+
+	if len(errs) == len(f.pipes) {
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+// Abort fails the batch for every sink.
+func (f *Fanout) Abort(err error) {
+	for _, p := range f.pipes {
+		p.writer.CloseWithError(err)
+
+		if doneErr := <-p.done; doneErr != nil {
+			p.err = doneErr
+		} else if p.err == nil {
+			p.err = err
+		}
+	}
+}
+
+// Results for each sink. Only complete after Close or Abort.
+func (f *Fanout) Results() []Result {
+	results := make([]Result, len(f.pipes))
+
+	for i, p := range f.pipes {
+		results[i] = Result{
+			Sink: p.sink.Name(),
+			Err:  p.err,
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,62 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File writes batches below a directory on the local filesystem. Useful for testing.
+type File struct {
+	directory string
+}
+
+// NewFile returns a sink which writes batches below the directory.
+func NewFile(directory string) *File {
+	return &File{
+		directory: directory,
+	}
+}
+
+// Name of the sink.
+func (s *File) Name() string {
+	return "file://" + s.directory
+}
+
+// Path returns the path a batch is written to.
+func (s *File) Path(batch Batch) string {
+	return filepath.Join(s.directory, filepath.FromSlash(batch.Key))
+}
+
+// Write a batch. It is staged alongside its final path so that partial batches are never visible.
+func (s *File) Write(_ context.Context, batch Batch, body io.Reader) error {
+	path := s.Path(batch)
+
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create directory, %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".batch-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file, %w", err)
+	}
+
+	_, err = io.Copy(file, body)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to write file, %w", err)
+	}
+
+	err = os.Rename(file.Name(), path)
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("failed to move file into place, %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	lambdaevents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const (
+	// EventNamePut is the event of objects uploaded in a single request.
+	EventNamePut = "ObjectCreated:Put"
+	// EventNameCompleteMultipartUpload is the event of objects uploaded in parts.
+	EventNameCompleteMultipartUpload = "ObjectCreated:CompleteMultipartUpload"
+)
+
+// SQSAPI used to send notifications.
+type SQSAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+// Notify uploads batches with an S3 sink, then sends an S3 event notification to a queue. This allows
+// consumers such as the Sentinel S3 connector to be pointed at a queue without configuring bucket notifications.
+type Notify struct {
+	*S3
+	client   SQSAPI
+	queueURL string
+	region   string
+}
+
+// NewNotify returns a sink which notifies a queue of each batch uploaded by the S3 sink.
+func NewNotify(s *S3, client SQSAPI, queueURL, region string) *Notify {
+	return &Notify{
+		S3:       s,
+		client:   client,
+		queueURL: queueURL,
+		region:   region,
+	}
+}
+
+// Write uploads a batch and sends a notification once it has been uploaded.
+func (n *Notify) Write(ctx context.Context, batch Batch, body io.Reader) error {
+	counter := &countingReader{Reader: body}
+
+	output, err := n.S3.upload(ctx, batch, counter)
+	if err != nil {
+		return err
+	}
+
+	message, err := json.Marshal(n.event(batch, output, counter.n, time.Now().UTC()))
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification, %w", err)
+	}
+
+	_, err = n.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(n.queueURL),
+		MessageBody: aws.String(string(message)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send notification to queue %q, %w", n.queueURL, err)
+	}
+
+	return nil
+}
+
+// Builds the notification S3 would have sent for the uploaded object.
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/notification-content-structure.html
+func (n *Notify) event(batch Batch, output *s3manager.UploadOutput, size int64, now time.Time) lambdaevents.S3Event {
+	// The uploader only starts a multipart upload when the body is larger than a part, which S3 reports differently.
+	name := EventNamePut
+	if output.UploadID != "" {
+		name = EventNameCompleteMultipartUpload
+	}
+
+	return lambdaevents.S3Event{
+		Records: []lambdaevents.S3EventRecord{
+			{
+				EventVersion: "2.1",
+				EventSource:  "aws:s3",
+				AWSRegion:    n.region,
+				EventTime:    now,
+				EventName:    name,
+				S3: lambdaevents.S3Entity{
+					SchemaVersion:   "1.0",
+					ConfigurationID: n.Name(),
+					Bucket: lambdaevents.S3Bucket{
+						Name: n.bucket,
+						Arn:  "arn:aws:s3:::" + n.bucket,
+					},
+					Object: lambdaevents.S3Object{
+						Key:       escapeKey(n.Key(batch)),
+						Size:      size,
+						ETag:      strings.Trim(aws.ToString(output.ETag), `"`),
+						VersionID: aws.ToString(output.VersionID),
+						Sequencer: fmt.Sprintf("%016X", now.UnixNano()),
+					},
+				},
+			},
+		},
+	}
+}
+
+// Keys are URL encoded in notifications, apart from the path separators.
+func escapeKey(key string) string {
+	return strings.ReplaceAll(url.QueryEscape(key), "%2F", "/")
+}
+
+// Counts the bytes read from a body.
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+// Read from the body.
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
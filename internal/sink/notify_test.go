@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lambdaevents "github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Stands in for SQS, recording the messages sent to each queue.
+type fakeSQS struct {
+	mu       sync.Mutex
+	messages map[string][]string
+}
+
+func (f *fakeSQS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "SendMessage" {
+		http.Error(w, "unsupported request", http.StatusBadRequest)
+		return
+	}
+
+	body := r.Form.Get("MessageBody")
+	sum := md5.Sum([]byte(body))
+
+	f.mu.Lock()
+	f.messages[r.Form.Get("QueueUrl")] = append(f.messages[r.Form.Get("QueueUrl")], body)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<SendMessageResponse><SendMessageResult><MD5OfMessageBody>%s</MD5OfMessageBody><MessageId>1</MessageId></SendMessageResult></SendMessageResponse>`, hex.EncodeToString(sum[:]))
+}
+
+func TestNotify(t *testing.T) {
+	queue := &fakeSQS{messages: make(map[string][]string)}
+
+	server := httptest.NewServer(queue)
+	defer server.Close()
+
+	client := sqs.New(sqs.Options{
+		Region:       "ap-southeast-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	uploader := &mockUploader{objects: make(map[string]string), partSize: 8}
+	queueURL := server.URL + "/123456789012/sentinel"
+
+	s := NewNotify(NewS3(uploader, "skpr-sentinel", "/sentinel", Object{}), client, queueURL, "ap-southeast-2")
+	assert.Equal(t, "s3://skpr-sentinel/sentinel", s.Name())
+
+	err := s.Write(context.Background(), batch, strings.NewReader("events"))
+	assert.NoError(t, err)
+	assert.Equal(t, "events", uploader.objects["skpr-sentinel/sentinel/"+batch.Key])
+
+	assert.Len(t, queue.messages[queueURL], 1)
+
+	var event lambdaevents.S3Event
+	err = json.Unmarshal([]byte(queue.messages[queueURL][0]), &event)
+	assert.NoError(t, err)
+	assert.Len(t, event.Records, 1)
+
+	record := event.Records[0]
+	assert.Equal(t, "aws:s3", record.EventSource)
+	assert.Equal(t, "ObjectCreated:Put", record.EventName)
+	assert.Equal(t, "ap-southeast-2", record.AWSRegion)
+	assert.Equal(t, "skpr-sentinel", record.S3.Bucket.Name)
+	assert.Equal(t, "arn:aws:s3:::skpr-sentinel", record.S3.Bucket.Arn)
+	assert.Equal(t, "sentinel/"+batch.Key, record.S3.Object.Key)
+	assert.Equal(t, "sentinel/"+batch.Key, record.S3.Object.URLDecodedKey)
+	assert.Equal(t, int64(len("events")), record.S3.Object.Size)
+	assert.WithinDuration(t, time.Now(), record.EventTime, time.Minute)
+
+	// Bodies larger than a part are uploaded in parts.
+	err = s.Write(context.Background(), batch, strings.NewReader("many more events"))
+	assert.NoError(t, err)
+	assert.Len(t, queue.messages[queueURL], 2)
+
+	err = json.Unmarshal([]byte(queue.messages[queueURL][1]), &event)
+	assert.NoError(t, err)
+	assert.Equal(t, "ObjectCreated:CompleteMultipartUpload", event.Records[0].EventName)
+}
+
+func TestEscapeKey(t *testing.T) {
+	assert.Equal(t, "year%3D2026/app+logs/fpm%3A1.gz", escapeKey("year=2026/app logs/fpm:1.gz"))
+}
+
+func TestNotifyUploadFailed(t *testing.T) {
+	queue := &fakeSQS{messages: make(map[string][]string)}
+
+	server := httptest.NewServer(queue)
+	defer server.Close()
+
+	client := sqs.New(sqs.Options{
+		Region:       "ap-southeast-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+
+	uploader := &mockUploader{err: fmt.Errorf("access denied")}
+
+	s := NewNotify(NewS3(uploader, "skpr-sentinel", "/sentinel", Object{}), client, server.URL+"/123456789012/sentinel", "ap-southeast-2")
+
+	err := s.Write(context.Background(), batch, strings.NewReader("events"))
+	assert.ErrorContains(t, err, "access denied")
+	assert.Empty(t, queue.messages)
+}
@@ -0,0 +1,179 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+)
+
+const (
+	// TagLogGroup is the object tag holding the log group of a batch.
+	TagLogGroup = "log_group"
+	// TagLogStream is the object tag holding the log stream of a batch.
+	TagLogStream = "log_stream"
+	// TagEventCount is the object tag holding the number of events in a batch.
+	TagEventCount = "event_count"
+)
+
+// DefaultContentEncoding of uploaded objects, as packages are gzipped.
+const DefaultContentEncoding = "gzip"
+
+// Characters which are not allowed in object tags eg. the "[$LATEST]" of Lambda log streams.
+var invalidTagRegex = regexp.MustCompile(`[^\p{L}\p{Z}\p{N}_.:/=+\-@]`)
+
+// Uploader used by the S3 sink.
+type Uploader interface {
+	Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error)
+}
+
+// Object sets the properties of uploaded objects. Empty fields are left to the defaults of the bucket.
+type Object struct {
+	// ServerSideEncryption eg. "aws:kms".
+	ServerSideEncryption string
+	// KMSKeyID encrypts objects when ServerSideEncryption is "aws:kms". Defaults to the AWS managed key.
+	KMSKeyID     string
+	StorageClass string
+	// TagBatch adds the TagLogGroup, TagLogStream and TagEventCount tags.
+	TagBatch bool
+	Tags     map[string]string
+	Metadata map[string]string
+	// ContentType defaults to the media type of the batch format.
+	ContentType string
+	// ContentEncoding defaults to DefaultContentEncoding.
+	ContentEncoding string
+}
+
+// S3 uploads batches as objects in a bucket.
+type S3 struct {
+	uploader Uploader
+	bucket   string
+	prefix   string
+	object   Object
+}
+
+// NewS3 returns a sink which uploads batches below the prefix of a bucket.
+func NewS3(uploader Uploader, bucket, prefix string, object Object) *S3 {
+	return &S3{
+		uploader: uploader,
+		bucket:   bucket,
+		prefix:   prefix,
+		object:   object,
+	}
+}
+
+// Name of the sink.
+func (s *S3) Name() string {
+	return "s3://" + path.Join(s.bucket, s.prefix)
+}
+
+// Bucket the sink uploads to.
+func (s *S3) Bucket() string {
+	return s.bucket
+}
+
+// Key returns the key a batch is uploaded to.
+func (s *S3) Key(batch Batch) string {
+	return strings.TrimPrefix(path.Join(s.prefix, batch.Key), "/")
+}
+
+// Write uploads a batch. Bodies which can't seek are sent as a multipart upload while they are read.
+func (s *S3) Write(ctx context.Context, batch Batch, body io.Reader) error {
+	_, err := s.upload(ctx, batch, body)
+	return err
+}
+
+// Uploads a batch and returns the details of the object.
+func (s *S3) upload(ctx context.Context, batch Batch, body io.Reader) (*s3manager.UploadOutput, error) {
+	output, err := s.uploader.Upload(ctx, s.input(batch, body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload to bucket %q, %w", s.bucket, err)
+	}
+
+	return output, nil
+}
+
+// Returns the request which uploads a batch.
+func (s *S3) input(batch Batch, body io.Reader) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(s.Key(batch)),
+		Body:            body,
+		ContentType:     aws.String(events.ContentType(batch.Format)),
+		ContentEncoding: aws.String(DefaultContentEncoding),
+		Metadata:        s.object.Metadata,
+	}
+
+	if s.object.ContentType != "" {
+		input.ContentType = aws.String(s.object.ContentType)
+	}
+
+	if s.object.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(s.object.ContentEncoding)
+	}
+
+	if batch.ContentType != "" {
+		input.ContentType = aws.String(batch.ContentType)
+		input.ContentEncoding = nil
+	}
+
+	if s.object.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(s.object.ServerSideEncryption)
+	}
+
+	if s.object.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.object.KMSKeyID)
+	}
+
+	if s.object.StorageClass != "" {
+		input.StorageClass = types.StorageClass(s.object.StorageClass)
+	}
+
+	if tags := s.tags(batch); len(tags) > 0 {
+		input.Tagging = aws.String(tags.Encode())
+	}
+
+	return input
+}
+
+// Returns the tags of the object a batch is uploaded to.
+func (s *S3) tags(batch Batch) url.Values {
+	tags := url.Values{}
+
+	for k, v := range s.object.Tags {
+		tags.Set(k, v)
+	}
+
+	if s.object.TagBatch {
+		tags.Set(TagLogGroup, tagValue(batch.GroupName))
+		tags.Set(TagLogStream, tagValue(batch.StreamName))
+
+		if batch.Count > 0 {
+			tags.Set(TagEventCount, strconv.Itoa(batch.Count))
+		}
+	}
+
+	return tags
+}
+
+// Replaces the characters which are not allowed in a tag value and truncates it to the maximum length.
+func tagValue(value string) string {
+	value = invalidTagRegex.ReplaceAllString(value, "_")
+
+	if runes := []rune(value); len(runes) > 256 {
+		value = string(runes[:256])
+	}
+
+	return value
+}
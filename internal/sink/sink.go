@@ -0,0 +1,53 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Batch describes a package of log events being delivered.
+type Batch struct {
+	// Key identifies the batch relative to the sink eg. "<stream>/<time>.gz".
+	Key        string
+	GroupName  string
+	StreamName string
+	// Format the events were packaged in eg. "csv".
+	Format string
+	// Columns of the package when Format is "csv".
+	Columns []string
+	// Start and End of the window the events were exported from.
+	Start time.Time
+	End   time.Time
+	// Count of events in the batch. Zero when it is not known before the batch is written eg. when streaming.
+	Count int
+	// ContentType of a body which is not a gzipped package of events eg. a manifest. Optional.
+	ContentType string
+}
+
+// Sink delivers packaged batches to a destination.
+type Sink interface {
+	// Name identifies the sink in logs and results.
+	Name() string
+	// Write delivers a batch. The body is read until EOF.
+	Write(ctx context.Context, batch Batch, body io.Reader) error
+}
+
+// Result of delivering a batch to a single sink.
+type Result struct {
+	Sink string
+	Err  error
+}
+
+// Failed returns the results which have an error.
+func Failed(results []Result) []Result {
+	var failed []Result
+
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
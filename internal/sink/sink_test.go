@@ -0,0 +1,190 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockUploader struct {
+	mu      sync.Mutex
+	objects map[string]string
+	err     error
+	// PartSize above which bodies are uploaded in parts. Optional.
+	partSize int
+}
+
+func (m *mockUploader) Upload(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.objects[*input.Bucket+"/"+*input.Key] = string(data)
+
+	if m.partSize > 0 && len(data) > m.partSize {
+		return &s3manager.UploadOutput{UploadID: "upload"}, nil
+	}
+
+	return &s3manager.UploadOutput{}, nil
+}
+
+var batch = Batch{
+	Key:        "ecs/app/123/20261016T000000Z.gz",
+	GroupName:  "/skpr/test/things",
+	StreamName: "ecs/app/123",
+}
+
+func TestS3(t *testing.T) {
+	uploader := &mockUploader{objects: make(map[string]string)}
+
+	s := NewS3(uploader, "skpr-test", "/my/test/prefix", Object{})
+	assert.Equal(t, "s3://skpr-test/my/test/prefix", s.Name())
+
+	err := s.Write(context.Background(), batch, strings.NewReader("events"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"skpr-test/my/test/prefix/ecs/app/123/20261016T000000Z.gz": "events",
+	}, uploader.objects)
+
+	// Prefixes don't need a leading slash.
+	assert.Equal(t, "s3://skpr-test/archive", NewS3(uploader, "skpr-test", "archive", Object{}).Name())
+	assert.Equal(t, "s3://skpr-test", NewS3(uploader, "skpr-test", "", Object{}).Name())
+}
+
+func TestS3Input(t *testing.T) {
+	s := NewS3(nil, "skpr-test", "/my/test/prefix", Object{})
+
+	input := s.input(batch, nil)
+	assert.Equal(t, "text/csv", *input.ContentType)
+	assert.Equal(t, "gzip", *input.ContentEncoding)
+	assert.Empty(t, input.ServerSideEncryption)
+	assert.Empty(t, input.StorageClass)
+	assert.Nil(t, input.Tagging)
+
+	s = NewS3(nil, "skpr-test", "/my/test/prefix", Object{
+		ServerSideEncryption: "aws:kms",
+		KMSKeyID:             "arn:aws:kms:ap-southeast-2:123456789012:key/sentinel",
+		StorageClass:         "STANDARD_IA",
+		TagBatch:             true,
+		Tags:                 map[string]string{"team": "platform"},
+		Metadata:             map[string]string{"source": "cloudwatch"},
+		ContentType:          "application/gzip",
+		ContentEncoding:      "identity",
+	})
+
+	lambda := batch
+	lambda.Format = "jsonl"
+	lambda.StreamName = "2026/10/16/[$LATEST]abc"
+	lambda.Count = 42
+
+	input = s.input(lambda, nil)
+	assert.Equal(t, "application/gzip", *input.ContentType)
+	assert.Equal(t, "identity", *input.ContentEncoding)
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, input.ServerSideEncryption)
+	assert.Equal(t, "arn:aws:kms:ap-southeast-2:123456789012:key/sentinel", *input.SSEKMSKeyId)
+	assert.Equal(t, types.StorageClassStandardIa, input.StorageClass)
+	assert.Equal(t, map[string]string{"source": "cloudwatch"}, input.Metadata)
+	assert.Equal(t, "event_count=42&log_group=%2Fskpr%2Ftest%2Fthings&log_stream=2026%2F10%2F16%2F__LATEST_abc&team=platform", *input.Tagging)
+
+	// The event count isn't known while streaming.
+	lambda.Count = 0
+
+	input = s.input(lambda, nil)
+	assert.NotContains(t, *input.Tagging, "event_count")
+
+	// Bodies which aren't packages describe themselves.
+	lambda.ContentType = "application/json"
+
+	input = s.input(lambda, nil)
+	assert.Equal(t, "application/json", *input.ContentType)
+	assert.Nil(t, input.ContentEncoding)
+}
+
+func TestFile(t *testing.T) {
+	s := NewFile(t.TempDir())
+
+	err := s.Write(context.Background(), batch, strings.NewReader("events"))
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(s.Path(batch))
+	assert.NoError(t, err)
+	assert.Equal(t, "events", string(data))
+}
+
+func TestFanout(t *testing.T) {
+	ctx := context.Background()
+
+	uploader := &mockUploader{objects: make(map[string]string)}
+	broken := &mockUploader{err: errors.New("access denied")}
+	file := NewFile(t.TempDir())
+
+	fanout := NewFanout(ctx, []Sink{
+		NewS3(uploader, "skpr-archive", "/archive", Object{}),
+		NewS3(broken, "skpr-sentinel", "/sentinel", Object{}),
+		file,
+	}, batch)
+
+	for _, line := range []string{"first\n", "second\n"} {
+		_, err := fanout.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, fanout.Close())
+
+	results := fanout.Results()
+	assert.Len(t, results, 3)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorContains(t, results[1].Err, "access denied")
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, []Result{results[1]}, Failed(results))
+
+	assert.Equal(t, "first\nsecond\n", uploader.objects["skpr-archive/archive/"+batch.Key])
+
+	data, err := os.ReadFile(file.Path(batch))
+	assert.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+}
+
+func TestFanoutAllFailed(t *testing.T) {
+	fanout := NewFanout(context.Background(), []Sink{
+		NewS3(&mockUploader{err: errors.New("access denied")}, "skpr-archive", "/archive", Object{}),
+	}, batch)
+
+	// The write may land before the sink fails, in which case Close reports the failure.
+	_, writeErr := fanout.Write([]byte("events"))
+	closeErr := fanout.Close()
+
+	assert.Error(t, errors.Join(writeErr, closeErr))
+	assert.Len(t, Failed(fanout.Results()), 1)
+}
+
+func TestFanoutAbort(t *testing.T) {
+	file := NewFile(t.TempDir())
+
+	fanout := NewFanout(context.Background(), []Sink{file}, batch)
+
+	_, err := fanout.Write([]byte("events"))
+	assert.NoError(t, err)
+
+	fanout.Abort(errors.New("throttled"))
+
+	assert.ErrorContains(t, fanout.Results()[0].Err, "throttled")
+	assert.NoFileExists(t, file.Path(batch))
+}
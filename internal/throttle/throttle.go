@@ -0,0 +1,131 @@
+package throttle
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	"golang.org/x/time/rate"
+)
+
+// Config of how clients back off from and avoid throttling.
+type Config struct {
+	// MaxAttempts of each request, including the first. Defaults to the SDK default of 3.
+	MaxAttempts int
+	// MaxBackoff between attempts. Delays grow exponentially with jitter up to this. Defaults to the SDK default of 20s.
+	MaxBackoff time.Duration
+	// Rate of requests per second shared by every request made with the client. Unlimited when zero.
+	Rate float64
+	// Burst of requests allowed above Rate. Defaults to 1.
+	Burst int
+}
+
+// Counter of the retries made by requests with a context.
+type Counter struct {
+	n atomic.Int64
+}
+
+// Count returns the number of retries.
+func (c *Counter) Count() int {
+	return int(c.n.Load())
+}
+
+type counterKey struct{}
+
+// WithCounter returns a context whose retries are counted.
+func WithCounter(ctx context.Context) (context.Context, *Counter) {
+	counter := &Counter{}
+	return context.WithValue(ctx, counterKey{}, counter), counter
+}
+
+// NewRetryer returns a retryer which backs off exponentially with jitter and counts retries against the context.
+// Retries are not limited by a quota, so that sustained throttling is backed off from rather than failing once the
+// SDK's default quota of about 100 retries per client runs out.
+func NewRetryer(config Config) aws.Retryer {
+	return counting{
+		RetryerV2: retry.NewStandard(func(o *retry.StandardOptions) {
+			o.RateLimiter = unlimited{}
+
+			if config.MaxAttempts > 0 {
+				o.MaxAttempts = config.MaxAttempts
+			}
+
+			if config.MaxBackoff > 0 {
+				o.MaxBackoff = config.MaxBackoff
+				o.Backoff = retry.NewExponentialJitterBackoff(config.MaxBackoff)
+			}
+		}),
+	}
+}
+
+// Retry quota which never runs out.
+type unlimited struct{}
+
+// GetToken always grants a retry.
+func (unlimited) GetToken(context.Context, uint) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+// AddTokens does nothing as the quota never runs out.
+func (unlimited) AddTokens(uint) error {
+	return nil
+}
+
+// Retryer which counts the retries it allows.
+type counting struct {
+	aws.RetryerV2
+}
+
+// GetRetryToken is called before each retry.
+func (c counting) GetRetryToken(ctx context.Context, opErr error) (func(error) error, error) {
+	release, err := c.RetryerV2.GetRetryToken(ctx, opErr)
+
+	if counter, ok := ctx.Value(counterKey{}).(*Counter); ok && err == nil {
+		counter.n.Add(1)
+	}
+
+	return release, err
+}
+
+// Limiter spaces out requests so that clients share a budget of requests per second.
+type Limiter struct {
+	limiter *rate.Limiter
+}
+
+// NewLimiter returns a limiter for the rate of the config. Returns nil when the rate is unlimited.
+func NewLimiter(config Config) *Limiter {
+	if config.Rate <= 0 {
+		return nil
+	}
+
+	burst := config.Burst
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		limiter: rate.NewLimiter(rate.Limit(config.Rate), burst),
+	}
+}
+
+// ID of the middleware.
+func (*Limiter) ID() string {
+	return "RateLimit"
+}
+
+// HandleFinalize waits for a turn before each attempt of a request, including retries.
+func (l *Limiter) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	if err := l.limiter.Wait(ctx); err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+	}
+
+	return next.HandleFinalize(ctx, in)
+}
+
+// AddTo adds the limiter to the middleware stack of a client. Used as an APIOption.
+func (l *Limiter) AddTo(stack *middleware.Stack) error {
+	return stack.Finalize.Insert(l, (&retry.Attempt{}).ID(), middleware.After)
+}
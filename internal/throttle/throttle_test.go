@@ -0,0 +1,96 @@
+package throttle
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+type throttlingError struct{}
+
+func (throttlingError) Error() string     { return "rate exceeded" }
+func (throttlingError) ErrorCode() string { return "ThrottlingException" }
+
+func TestRetryerCounts(t *testing.T) {
+	retryer := NewRetryer(Config{MaxAttempts: 7, MaxBackoff: time.Second})
+	assert.Equal(t, 7, retryer.MaxAttempts())
+	assert.True(t, retryer.IsErrorRetryable(throttlingError{}))
+
+	ctx, counter := WithCounter(context.Background())
+
+	for i := 0; i < 2; i++ {
+		_, err := retryer.GetRetryToken(ctx, throttlingError{})
+		assert.NoError(t, err)
+	}
+
+	// Retries of other contexts are not counted.
+	_, err := retryer.GetRetryToken(context.Background(), throttlingError{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, counter.Count())
+
+	delay, err := retryer.RetryDelay(10, throttlingError{})
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, delay, time.Second)
+}
+
+func TestRetryerSustainedThrottling(t *testing.T) {
+	retryer := NewRetryer(Config{})
+
+	ctx, counter := WithCounter(context.Background())
+
+	// The default quota of the SDK runs out after about 100 throttled retries.
+	for i := 0; i < 1000; i++ {
+		_, err := retryer.GetRetryToken(ctx, throttlingError{})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1000, counter.Count())
+}
+
+func TestLimiter(t *testing.T) {
+	assert.Nil(t, NewLimiter(Config{}))
+
+	limiter := NewLimiter(Config{Rate: 50})
+
+	next := middleware.FinalizeHandlerFunc(func(context.Context, middleware.FinalizeInput) (middleware.FinalizeOutput, middleware.Metadata, error) {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, nil
+	})
+
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		_, _, err := limiter.HandleFinalize(context.Background(), middleware.FinalizeInput{}, next)
+		assert.NoError(t, err)
+	}
+
+	// The first request is let through straight away, then one every 20ms.
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// Waiting stops with the request.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := limiter.HandleFinalize(ctx, middleware.FinalizeInput{}, next)
+	assert.Error(t, err)
+}
+
+func TestLimiterAddTo(t *testing.T) {
+	stack := middleware.NewStack("test", smithyhttp.NewStackRequest)
+
+	err := retry.AddRetryMiddlewares(stack, retry.AddRetryMiddlewaresOptions{Retryer: NewRetryer(Config{})})
+	assert.NoError(t, err)
+
+	err = NewLimiter(Config{Rate: 1}).AddTo(stack)
+	assert.NoError(t, err)
+
+	list := stack.Finalize.List()
+	assert.Contains(t, list, "RateLimit")
+	assert.Less(t, slices.Index(list, "Retry"), slices.Index(list, "RateLimit"))
+}
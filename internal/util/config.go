@@ -2,48 +2,564 @@ package util
 
 import (
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/streams"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/key"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/redact"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/throttle"
+)
+
+const (
+	// CheckpointStoreS3 persists checkpoints as objects in an S3 bucket.
+	CheckpointStoreS3 = "s3"
+	// CheckpointStoreFile persists checkpoints on the local filesystem.
+	CheckpointStoreFile = "file"
 )
 
+// DefaultMaxWindow is the longest window a single run may export unless configured otherwise.
+const DefaultMaxWindow = 24 * time.Hour
+
 // Config used by this application.
 type Config struct {
-	GroupName          string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_GROUP_NAME"`
-	StreamName         string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_STREAM_NAME"`
-	Start              time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_START"`
-	End                time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_END"`
-	BucketName         string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_BUCKET_NAME"`
-	BucketPrefix       string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_BUCKET_PREFIX"`
-	TemporaryDirectory string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_TEMPORARY_DIRECTORY"`
+	GroupName   string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_GROUP_NAME"`
+	StreamName  string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_STREAM_NAME"`
+	StreamMatch string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_STREAM_MATCH"`
+	AllStreams  bool          `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_ALL_STREAMS"`
+	Start       time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_START"`
+	End         time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_END"`
+	// Align the window to a boundary eg. "1h" for the top of the hour.
+	Align time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_ALIGN"`
+	// FilterPattern only exports the events which match a CloudWatch Logs filter pattern eg. "?ERROR ?WARN". Optional.
+	FilterPattern string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_FILTER_PATTERN"`
+	// MultilineStart matches the first line of each event. Lines which don't match are merged into the event before them.
+	// Disabled when empty.
+	MultilineStart string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MULTILINE_START"`
+	// MultilineMaxLines and MultilineMaxGap limit the lines merged into a single event. Unlimited when zero.
+	MultilineMaxLines int           `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MULTILINE_MAX_LINES"`
+	MultilineMaxGap   time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MULTILINE_MAX_GAP"`
+	// RedactDetectors are the built-in detectors applied to messages eg. "email,ipv4,pan". Optional.
+	RedactDetectors []string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_REDACT_DETECTORS"`
+	// RedactAction applied to the matches of RedactDetectors. One of "mask", "hash" or "drop". Defaults to "mask".
+	RedactAction string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_REDACT_ACTION"`
+	// RedactHashKey used to HMAC matches with the "hash" action. Jobs fall back to it.
+	RedactHashKey string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_REDACT_HASH_KEY"`
+	// Format of packages. One of "csv" or "jsonl".
+	Format string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_FORMAT"`
+	// Columns written by the "csv" format eg. "timestamp,group,stream,message".
+	Columns []string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_COLUMNS"`
+	// KeyTemplate names the object of each batch eg. "{group}/year={year}/month={month}/day={day}/{stream}.gz".
+	KeyTemplate string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_KEY_TEMPLATE"`
+	// Manifest writes a manifest of the objects produced by each run to the S3 and file sinks.
+	Manifest            bool   `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MANIFEST"`
+	ManifestKeyTemplate string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MANIFEST_KEY_TEMPLATE"`
+	// MaxPartEvents, MaxPartBytes and MaxPartCompressedBytes split packages into parts. Unlimited when zero.
+	MaxPartEvents          int    `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MAX_PART_EVENTS"`
+	MaxPartBytes           int64  `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MAX_PART_BYTES"`
+	MaxPartCompressedBytes int64  `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MAX_PART_COMPRESSED_BYTES"`
+	BucketName             string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_BUCKET_NAME"`
+	BucketPrefix           string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_BUCKET_PREFIX"`
+	TemporaryDirectory     string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_TEMPORARY_DIRECTORY"`
+	// SSE is the server-side encryption of uploads. One of "AES256", "aws:kms" or "aws:kms:dsse". Optional.
+	SSE string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_SSE"`
+	// KMSKeyID encrypts uploads when SSE uses KMS. Optional.
+	KMSKeyID string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_KMS_KEY_ID"`
+	// StorageClass of uploads eg. "STANDARD_IA". Optional.
+	StorageClass string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_STORAGE_CLASS"`
+	// TagBatch tags uploads with their log group, log stream and event count.
+	TagBatch bool `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_TAG_BATCH"`
+	// ContentType and ContentEncoding of uploads. Default to the media type of the format and "gzip".
+	ContentType     string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CONTENT_TYPE"`
+	ContentEncoding string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CONTENT_ENCODING"`
+	// QueueURL is sent an S3 event notification after each upload. Optional.
+	QueueURL string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_QUEUE_URL"`
+	// SQSEndpoint overrides the SQS endpoint eg. to use a local stand-in. Optional.
+	SQSEndpoint string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_SQS_ENDPOINT"`
+	// Streaming uploads packages to S3 as they are written instead of staging them in TemporaryDirectory.
+	Streaming bool `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_STREAMING"`
+	// SafetyMargin is the time left before the function times out at which exports stop and upload what they have.
+	SafetyMargin time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_SAFETY_MARGIN"`
+	// Concurrency is the number of jobs run at the same time. Defaults to 1.
+	Concurrency int `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CONCURRENCY"`
+	// MaxAttempts of each AWS request, including the first. Throttled requests are retried with jittered exponential backoff.
+	// Defaults to 5.
+	MaxAttempts int `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MAX_ATTEMPTS"`
+	// MaxBackoff between attempts of an AWS request.
+	MaxBackoff time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MAX_BACKOFF"`
+	// CloudWatchLogsRate limits the requests per second made to CloudWatch Logs by all jobs. Unlimited when zero.
+	CloudWatchLogsRate  float64 `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CLOUDWATCH_LOGS_RATE"`
+	CloudWatchLogsBurst int     `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CLOUDWATCH_LOGS_BURST"`
+	// MaxWindow is the longest window a single run may export. Defaults to DefaultMaxWindow.
+	MaxWindow time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_MAX_WINDOW"`
+	JobsFile  string        `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_JOBS_FILE"`
+	// CheckpointStore used to resume streams from the last exported event. One of "s3" or "file". Disabled when empty.
+	CheckpointStore      string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_STORE"`
+	CheckpointBucketName string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_BUCKET_NAME"`
+	CheckpointPrefix     string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_PREFIX"`
+	CheckpointDirectory  string `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_DIRECTORY"`
+	// Jobs loaded from JobsFile.
+	Jobs []Job `mapstructure:"-"`
+	// StartTime and EndTime request an absolute window for every job. Set by an invocation payload.
+	StartTime time.Time `mapstructure:"-"`
+	EndTime   time.Time `mapstructure:"-"`
+	// BackfillFrom and BackfillTo request a backfill of every job. Set by an invocation payload.
+	BackfillFrom time.Time `mapstructure:"-"`
+	BackfillTo   time.Time `mapstructure:"-"`
+	// BackfillChunk is the length of each window exported by a backfill.
+	BackfillChunk time.Duration `mapstructure:"CLOUDWATCH_LOGS_SENTINEL_BACKFILL_CHUNK"`
+}
+
+// Backfilling reports whether a backfill was requested.
+func (c Config) Backfilling() bool {
+	return !c.BackfillFrom.IsZero() || !c.BackfillTo.IsZero()
+}
+
+// ExportJobs returns the jobs to run. When no jobs file is configured, a single job is
+// built from the flat config. Fields which are not set on a job fall back to the flat config.
+func (c Config) ExportJobs() []Job {
+	if c.JobsFile == "" {
+		return []Job{
+			{
+				Name:                   c.GroupName,
+				GroupName:              c.GroupName,
+				StreamName:             c.StreamName,
+				StreamMatch:            c.StreamMatch,
+				AllStreams:             c.AllStreams,
+				Start:                  c.Start,
+				End:                    c.End,
+				Format:                 c.Format,
+				FilterPattern:          c.FilterPattern,
+				Multiline:              c.multiline(),
+				Redact:                 c.redact(),
+				Columns:                c.Columns,
+				KeyTemplate:            c.KeyTemplate,
+				Manifest:               c.manifest(),
+				ManifestKeyTemplate:    c.ManifestKeyTemplate,
+				MaxPartEvents:          c.MaxPartEvents,
+				MaxPartBytes:           c.MaxPartBytes,
+				MaxPartCompressedBytes: c.MaxPartCompressedBytes,
+				BucketName:             c.BucketName,
+				BucketPrefix:           c.BucketPrefix,
+				QueueURL:               c.QueueURL,
+				Object:                 c.object(),
+				StartTime:              c.StartTime,
+				EndTime:                c.EndTime,
+			},
+		}
+	}
+
+	jobs := make([]Job, len(c.Jobs))
+
+	for i, job := range c.Jobs {
+		if job.Name == "" {
+			job.Name = job.GroupName
+		}
+
+		if job.StreamMatch == "" {
+			job.StreamMatch = c.StreamMatch
+		}
+
+		if job.Start == 0 && job.End == 0 {
+			job.Start = c.Start
+			job.End = c.End
+		}
+
+		if job.Format == "" {
+			job.Format = c.Format
+		}
+
+		if job.FilterPattern == "" {
+			job.FilterPattern = c.FilterPattern
+		}
+
+		if job.Multiline.Start == "" {
+			job.Multiline.Start = c.MultilineStart
+		}
+
+		defaults := c.multiline()
+
+		if job.Multiline.MaxLines == nil {
+			job.Multiline.MaxLines = defaults.MaxLines
+		}
+
+		if job.Multiline.MaxGap == nil {
+			job.Multiline.MaxGap = defaults.MaxGap
+		}
+
+		if len(job.Redact.Rules) == 0 {
+			job.Redact.Rules = c.redact().Rules
+		}
+
+		if job.Redact.HashKey == "" {
+			job.Redact.HashKey = c.RedactHashKey
+		}
+
+		if len(job.Columns) == 0 {
+			job.Columns = c.Columns
+		}
+
+		if job.KeyTemplate == "" {
+			job.KeyTemplate = c.KeyTemplate
+		}
+
+		job.Object = job.Object.withDefaults(c.object())
+
+		if job.Manifest == nil {
+			job.Manifest = c.manifest()
+		}
+
+		if job.ManifestKeyTemplate == "" {
+			job.ManifestKeyTemplate = c.ManifestKeyTemplate
+		}
+
+		if job.MaxPartEvents == 0 {
+			job.MaxPartEvents = c.MaxPartEvents
+		}
+
+		if job.MaxPartBytes == 0 {
+			job.MaxPartBytes = c.MaxPartBytes
+		}
+
+		if job.MaxPartCompressedBytes == 0 {
+			job.MaxPartCompressedBytes = c.MaxPartCompressedBytes
+		}
+
+		if job.BucketName == "" {
+			job.BucketName = c.BucketName
+		}
+
+		if job.BucketPrefix == "" {
+			job.BucketPrefix = c.BucketPrefix
+		}
+
+		if job.QueueURL == "" {
+			job.QueueURL = c.QueueURL
+		}
+
+		job.StartTime = c.StartTime
+		job.EndTime = c.EndTime
+
+		jobs[i] = job
+	}
+
+	return jobs
+}
+
+// Returns the object properties of uploads.
+func (c Config) object() Object {
+	object := Object{
+		SSE:             c.SSE,
+		KMSKeyID:        c.KMSKeyID,
+		StorageClass:    c.StorageClass,
+		ContentType:     c.ContentType,
+		ContentEncoding: c.ContentEncoding,
+	}
+
+	// Left unset when off, so that the flat config doesn't count as setting object properties.
+	if c.TagBatch {
+		object.TagBatch = &c.TagBatch
+	}
+
+	return object
+}
+
+// Returns whether manifests are written, left unset when they aren't.
+func (c Config) manifest() *bool {
+	if !c.Manifest {
+		return nil
+	}
+
+	return &c.Manifest
+}
+
+// Returns how continuation lines are merged. Limits are left unset when zero since both mean unlimited.
+func (c Config) multiline() Multiline {
+	multiline := Multiline{
+		Start: c.MultilineStart,
+	}
+
+	if c.MultilineMaxLines != 0 {
+		multiline.MaxLines = &c.MultilineMaxLines
+	}
+
+	if c.MultilineMaxGap != 0 {
+		multiline.MaxGap = &c.MultilineMaxGap
+	}
+
+	return multiline
+}
+
+// Returns the redaction rules built from the built-in detectors.
+func (c Config) redact() Redact {
+	r := Redact{
+		HashKey: c.RedactHashKey,
+	}
+
+	for _, detector := range c.RedactDetectors {
+		r.Rules = append(r.Rules, RedactRule{
+			Detector: detector,
+			Action:   c.RedactAction,
+		})
+	}
+
+	return r
+}
+
+// Lookback returns MaxWindow, or DefaultMaxWindow when it isn't set. Streams are discovered this far before the
+// window when resuming from checkpoints.
+func (c Config) Lookback() time.Duration {
+	if c.MaxWindow == 0 {
+		return DefaultMaxWindow
+	}
+
+	return c.MaxWindow
+}
+
+// Throttle returns how AWS clients back off from and avoid throttling.
+func (c Config) Throttle() throttle.Config {
+	return throttle.Config{
+		MaxAttempts: c.MaxAttempts,
+		MaxBackoff:  c.MaxBackoff,
+		Rate:        c.CloudWatchLogsRate,
+		Burst:       c.CloudWatchLogsBurst,
+	}
 }
 
 // Validate validates the config.
 func (c Config) Validate() []string {
 	var errors []string
 
+	if c.JobsFile != "" {
+		errors = append(errors, c.validateJobs()...)
+	} else {
+		errors = append(errors, c.validateFlat()...)
+	}
+
+	// Streaming uploads packages as they are written so nothing is staged.
+	if !c.Streaming {
+		if c.TemporaryDirectory == "" {
+			errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_TEMPORARY_DIRECTORY is a required variable")
+		} else if err := checkWritable(c.TemporaryDirectory); err != nil {
+			errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_TEMPORARY_DIRECTORY is not writable: %s", err))
+		}
+	}
+
+	maxWindow := c.Lookback()
+
+	for _, job := range c.ExportJobs() {
+		if length := job.Length(); length > maxWindow {
+			errors = append(errors, fmt.Sprintf("job %q exports a window of %s which exceeds CLOUDWATCH_LOGS_SENTINEL_MAX_WINDOW of %s", job.Name, length, maxWindow))
+		}
+	}
+
+	if c.Backfilling() {
+		if !c.BackfillFrom.Before(c.BackfillTo) {
+			errors = append(errors, "backfill from should be before to")
+		}
+
+		if c.BackfillChunk <= 0 {
+			errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_BACKFILL_CHUNK should be a positive duration")
+		}
+
+		if c.BackfillChunk > maxWindow {
+			errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_BACKFILL_CHUNK exceeds CLOUDWATCH_LOGS_SENTINEL_MAX_WINDOW of %s", maxWindow))
+		}
+
+		if !c.StartTime.IsZero() || !c.EndTime.IsZero() {
+			errors = append(errors, "backfill cannot be combined with start_time and end_time")
+		}
+	}
+
+	if c.Concurrency < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_CONCURRENCY should not be negative")
+	}
+
+	if c.MaxAttempts < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_MAX_ATTEMPTS should not be negative")
+	}
+
+	if c.MaxBackoff < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_MAX_BACKOFF should not be a negative duration")
+	}
+
+	if c.CloudWatchLogsRate < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_CLOUDWATCH_LOGS_RATE should not be negative")
+	}
+
+	if c.CloudWatchLogsBurst < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_CLOUDWATCH_LOGS_BURST should not be negative")
+	}
+
+	if c.SafetyMargin < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_SAFETY_MARGIN should not be a negative duration")
+	}
+
+	if c.SQSEndpoint != "" {
+		if err := checkURL(c.SQSEndpoint); err != nil {
+			errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_SQS_ENDPOINT is invalid: %s", err))
+		}
+	}
+
+	if c.Align < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_ALIGN should not be a negative duration")
+	}
+
+	switch c.CheckpointStore {
+	case "":
+	case CheckpointStoreS3:
+		if c.CheckpointBucketName == "" {
+			errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_BUCKET_NAME is a required variable when using the s3 checkpoint store")
+		} else if err := checkBucketName(c.CheckpointBucketName); err != nil {
+			errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_BUCKET_NAME is invalid: %s", err))
+		}
+	case CheckpointStoreFile:
+		if c.CheckpointDirectory == "" {
+			errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_DIRECTORY is a required variable when using the file checkpoint store")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_CHECKPOINT_STORE must be one of %q or %q", CheckpointStoreS3, CheckpointStoreFile))
+	}
+
+	return errors
+}
+
+// Validates the jobs loaded from the jobs file.
+func (c Config) validateJobs() []string {
+	var errors []string
+
+	if len(c.Jobs) == 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_JOBS_FILE does not declare any jobs")
+	}
+
+	names := make(map[string]bool)
+
+	for i, job := range c.ExportJobs() {
+		for _, problem := range job.Validate() {
+			errors = append(errors, fmt.Sprintf("job %d (%s): %s", i, job.Name, problem))
+		}
+
+		if names[job.Name] {
+			errors = append(errors, fmt.Sprintf("job %d (%s): name must be unique", i, job.Name))
+		}
+
+		names[job.Name] = true
+	}
+
+	return errors
+}
+
+// Validates the single job declared by environment variables.
+func (c Config) validateFlat() []string {
+	var errors []string
+
 	if c.GroupName == "" {
 		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_GROUP_NAME is a required variable")
 	}
 
-	if c.StreamName == "" {
-		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_STREAM_NAME is a required variable")
+	if c.StreamName == "" && !c.AllStreams {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_STREAM_NAME is a required variable when CLOUDWATCH_LOGS_SENTINEL_ALL_STREAMS is not set")
+	}
+
+	if _, err := streams.NewMatcher(c.StreamMatch, c.StreamName); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_STREAM_MATCH is invalid: %s", err))
 	}
 
 	if c.Start.Milliseconds() >= c.End.Milliseconds() {
 		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_START should be a duration before CLOUDWATCH_LOGS_SENTINEL_END")
 	}
 
+	if (!c.StartTime.IsZero() || !c.EndTime.IsZero()) && !c.StartTime.Before(c.EndTime) {
+		errors = append(errors, "start_time should be before end_time")
+	}
+
+	if err := events.CheckFormat(c.Format); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_FORMAT is invalid: %s", err))
+	}
+
+	if err := checkFilterPattern(c.FilterPattern); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_FILTER_PATTERN is invalid: %s", err))
+	}
+
+	if _, err := regexp.Compile(c.MultilineStart); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_MULTILINE_START is invalid: %s", err))
+	}
+
+	if c.MultilineMaxLines < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_MULTILINE_MAX_LINES should not be negative")
+	}
+
+	if c.MultilineMaxGap < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_MULTILINE_MAX_GAP should not be a negative duration")
+	}
+
+	for _, detector := range c.RedactDetectors {
+		if !slices.Contains(redact.Detectors, detector) {
+			errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_REDACT_DETECTORS is invalid: unknown detector %q", detector))
+		}
+	}
+
+	switch c.RedactAction {
+	case "", redact.ActionMask, redact.ActionDrop:
+	case redact.ActionHash:
+		if c.RedactHashKey == "" {
+			errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_REDACT_HASH_KEY is a required variable when CLOUDWATCH_LOGS_SENTINEL_REDACT_ACTION is hash")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_REDACT_ACTION must be one of %q, %q or %q", redact.ActionMask, redact.ActionHash, redact.ActionDrop))
+	}
+
+	if err := events.CheckColumns(c.Columns); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_COLUMNS is invalid: %s", err))
+	}
+
+	if err := key.Check(c.KeyTemplate); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_KEY_TEMPLATE is invalid: %s", err))
+	}
+
+	if err := key.Check(c.ManifestKeyTemplate); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_MANIFEST_KEY_TEMPLATE is invalid: %s", err))
+	}
+
+	if err := checkSSE(c.SSE, c.KMSKeyID); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_SSE is invalid: %s", err))
+	}
+
+	if err := checkStorageClass(c.StorageClass); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_STORAGE_CLASS is invalid: %s", err))
+	}
+
+	if c.MaxPartEvents < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_MAX_PART_EVENTS should not be negative")
+	}
+
+	if c.MaxPartBytes < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_MAX_PART_BYTES should not be negative")
+	}
+
+	if c.MaxPartCompressedBytes < 0 {
+		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_MAX_PART_COMPRESSED_BYTES should not be negative")
+	}
+
 	if c.BucketName == "" {
 		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_BUCKET_NAME is a required variable")
+	} else if err := checkBucketName(c.BucketName); err != nil {
+		errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_BUCKET_NAME is invalid: %s", err))
 	}
 
 	if c.BucketPrefix == "" {
 		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_BUCKET_PREFIX is a required variable")
 	}
 
-	if c.TemporaryDirectory == "" {
-		errors = append(errors, "CLOUDWATCH_LOGS_SENTINEL_TEMPORARY_DIRECTORY is a required variable")
+	if c.QueueURL != "" {
+		if err := checkURL(c.QueueURL); err != nil {
+			errors = append(errors, fmt.Sprintf("CLOUDWATCH_LOGS_SENTINEL_QUEUE_URL is invalid: %s", err))
+		}
 	}
 
 	return errors
@@ -68,5 +584,39 @@ func LoadConfig(path string) (Config, error) {
 		return config, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	if config.JobsFile != "" {
+		jobsFile := config.JobsFile
+
+		// Relative paths are resolved against the config directory.
+		if !filepath.IsAbs(jobsFile) {
+			jobsFile = filepath.Join(path, jobsFile)
+		}
+
+		config.Jobs, err = LoadJobs(jobsFile)
+		if err != nil {
+			return config, fmt.Errorf("failed to load jobs file: %w", err)
+		}
+	}
+
 	return config, err
 }
+
+// LoadValidConfig reads configuration, applies the override and validates the result.
+// Every problem with the config is returned as a single *ValidationError.
+func LoadValidConfig(path string, override Override) (Config, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return config, err
+	}
+
+	config, err = override.Apply(config)
+	if err != nil {
+		return config, fmt.Errorf("failed to apply override: %w", err)
+	}
+
+	if problems := config.Validate(); len(problems) > 0 {
+		return config, &ValidationError{Problems: problems}
+	}
+
+	return config, nil
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -12,13 +13,46 @@ func TestLoadConfig(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "/skpr/test/things", config.GroupName)
 	assert.Equal(t, "fpm", config.StreamName)
+	assert.Equal(t, "exact", config.StreamMatch)
+	assert.False(t, config.AllStreams)
 	assert.Equal(t, -time.Hour*1, config.Start)
 	assert.Equal(t, time.Duration(0), config.End)
+	assert.Equal(t, time.Duration(0), config.Align)
+	assert.Equal(t, time.Hour*24, config.MaxWindow)
+	assert.Equal(t, time.Minute, config.SafetyMargin)
+	assert.Equal(t, 1, config.Concurrency)
+	assert.Equal(t, 5, config.MaxAttempts)
+	assert.Equal(t, time.Second*20, config.MaxBackoff)
+	assert.Equal(t, float64(0), config.CloudWatchLogsRate)
+	assert.Equal(t, 1, config.CloudWatchLogsBurst)
+	assert.False(t, config.Streaming)
+	assert.Equal(t, "", config.FilterPattern)
+	assert.Equal(t, "", config.MultilineStart)
+	assert.Equal(t, 500, config.MultilineMaxLines)
+	assert.Equal(t, time.Second*5, config.MultilineMaxGap)
+	assert.Empty(t, config.RedactDetectors)
+	assert.Equal(t, "mask", config.RedactAction)
+	assert.Equal(t, "", config.RedactHashKey)
+	assert.Equal(t, "csv", config.Format)
+	assert.Equal(t, []string{"timestamp", "message"}, config.Columns)
+	assert.Equal(t, "{stream}/{end}.gz", config.KeyTemplate)
+	assert.False(t, config.Manifest)
+	assert.Equal(t, "manifests/{job}/{end}.json", config.ManifestKeyTemplate)
+	assert.Equal(t, 0, config.MaxPartEvents)
+	assert.Equal(t, int64(0), config.MaxPartBytes)
+	assert.Equal(t, int64(0), config.MaxPartCompressedBytes)
+	assert.Equal(t, "", config.SSE)
+	assert.Equal(t, "", config.StorageClass)
+	assert.False(t, config.TagBatch)
 	assert.Equal(t, "skpr-test", config.BucketName)
 	assert.Equal(t, "/my/test/prefix", config.BucketPrefix)
+	assert.Equal(t, "", config.CheckpointStore)
+	assert.Equal(t, "checkpoints", config.CheckpointPrefix)
 }
 
 func TestValidate(t *testing.T) {
+	maxLines := -1
+
 	var tests = []struct {
 		name   string
 		config Config
@@ -85,6 +119,424 @@ func TestValidate(t *testing.T) {
 			},
 			fails: false,
 		},
+		{
+			name: "Stream name is not required when exporting all streams",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				AllStreams:         true,
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: false,
+		},
+		{
+			name: "Stream match needs to be a known mode",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm-*",
+				StreamMatch:        "wildcard",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Stream regex needs to compile",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "^ecs/app/(",
+				StreamMatch:        "regex",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Checkpoint store needs to be a known backend",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+				CheckpointStore:    "dynamodb",
+			},
+			fails: true,
+		},
+		{
+			name: "Checkpoint bucket needs to be set when using S3",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+				CheckpointStore:    "s3",
+			},
+			fails: true,
+		},
+		{
+			name: "Checkpoint directory is set when using the filesystem",
+			config: Config{
+				GroupName:           "/skpr/test/things",
+				StreamName:          "fpm",
+				BucketName:          "skpr-test",
+				BucketPrefix:        "/my/test/prefix",
+				TemporaryDirectory:  "/tmp",
+				Start:               -time.Hour * 3,
+				CheckpointStore:     "file",
+				CheckpointDirectory: "/tmp/checkpoints",
+			},
+			fails: false,
+		},
+		{
+			name: "Bucket name needs to be valid",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "Skpr_Test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Temporary directory needs to be writable",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/does/not/exist",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Temporary directory is not required when streaming",
+			config: Config{
+				GroupName:    "/skpr/test/things",
+				StreamName:   "fpm",
+				BucketName:   "skpr-test",
+				BucketPrefix: "/my/test/prefix",
+				Start:        -time.Hour * 3,
+				Streaming:    true,
+			},
+			fails: false,
+		},
+		{
+			name: "Queue URL needs to be absolute",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				QueueURL:           "sentinel",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Format needs to be known",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				Format:             "xml",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Columns need to be known",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				Columns:            []string{"timestamp", "level"},
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Key template needs to use known variables",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				KeyTemplate:        "{stream}/{minute}.gz",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Encryption needs to be supported by S3",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				SSE:                "AES256",
+				KMSKeyID:           "alias/sentinel",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Filter pattern needs to be within the limits of CloudWatch Logs",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				FilterPattern:      strings.Repeat("?ERROR ", 200),
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Job filter rules are valid",
+			config: Config{
+				JobsFile:           "jobs.yaml",
+				TemporaryDirectory: "/tmp",
+				Jobs: []Job{
+					{
+						GroupName:    "/skpr/test/things",
+						StreamName:   "fpm",
+						Start:        -time.Hour,
+						BucketName:   "skpr-archive",
+						BucketPrefix: "/archive",
+						Filter: Filter{
+							Exclude: []string{"GET /healthz"},
+							Fields:  []string{`level != "debug"`},
+						},
+					},
+				},
+			},
+			fails: false,
+		},
+		{
+			name: "Job filter patterns need to be regular expressions",
+			config: Config{
+				JobsFile:           "jobs.yaml",
+				TemporaryDirectory: "/tmp",
+				Jobs: []Job{
+					{
+						GroupName:    "/skpr/test/things",
+						StreamName:   "fpm",
+						Start:        -time.Hour,
+						BucketName:   "skpr-archive",
+						BucketPrefix: "/archive",
+						Filter: Filter{
+							Include: []string{"("},
+						},
+					},
+				},
+			},
+			fails: true,
+		},
+		{
+			name: "Job filter fields need a known operator",
+			config: Config{
+				JobsFile:           "jobs.yaml",
+				TemporaryDirectory: "/tmp",
+				Jobs: []Job{
+					{
+						GroupName:    "/skpr/test/things",
+						StreamName:   "fpm",
+						Start:        -time.Hour,
+						BucketName:   "skpr-archive",
+						BucketPrefix: "/archive",
+						Filter: Filter{
+							Fields: []string{"level is debug"},
+						},
+					},
+				},
+			},
+			fails: true,
+		},
+		{
+			name: "Multiline start needs to be a regular expression",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				MultilineStart:     "^[",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Redact detectors need to be built in",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				RedactDetectors:    []string{"email", "phone"},
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Job multiline start needs to be a regular expression",
+			config: Config{
+				JobsFile:           "jobs.yaml",
+				TemporaryDirectory: "/tmp",
+				Jobs: []Job{
+					{
+						GroupName:    "/skpr/test/things",
+						StreamName:   "fpm",
+						Start:        -time.Hour,
+						BucketName:   "skpr-archive",
+						BucketPrefix: "/archive",
+						Multiline: Multiline{
+							Start: "^[",
+						},
+					},
+				},
+			},
+			fails: true,
+		},
+		{
+			name: "Job multiline limits need to be positive",
+			config: Config{
+				JobsFile:           "jobs.yaml",
+				TemporaryDirectory: "/tmp",
+				Jobs: []Job{
+					{
+						GroupName:    "/skpr/test/things",
+						StreamName:   "fpm",
+						Start:        -time.Hour,
+						BucketName:   "skpr-archive",
+						BucketPrefix: "/archive",
+						Multiline: Multiline{
+							Start:    `^\[`,
+							MaxLines: &maxLines,
+						},
+					},
+				},
+			},
+			fails: true,
+		},
+		{
+			name: "Job redact rules are valid",
+			config: Config{
+				JobsFile:           "jobs.yaml",
+				TemporaryDirectory: "/tmp",
+				Jobs: []Job{
+					{
+						GroupName:    "/skpr/test/things",
+						StreamName:   "fpm",
+						Start:        -time.Hour,
+						BucketName:   "skpr-archive",
+						BucketPrefix: "/archive",
+						Redact: Redact{
+							Rules: []RedactRule{
+								{Detector: "email"},
+								{Name: "session", Pattern: `PHPSESSID=\w+`, Action: "drop"},
+							},
+						},
+					},
+				},
+			},
+			fails: false,
+		},
+		{
+			name: "Job redact hash action needs a key",
+			config: Config{
+				JobsFile:           "jobs.yaml",
+				TemporaryDirectory: "/tmp",
+				Jobs: []Job{
+					{
+						GroupName:    "/skpr/test/things",
+						StreamName:   "fpm",
+						Start:        -time.Hour,
+						BucketName:   "skpr-archive",
+						BucketPrefix: "/archive",
+						Redact: Redact{
+							Rules: []RedactRule{
+								{Detector: "email", Action: "hash"},
+							},
+						},
+					},
+				},
+			},
+			fails: true,
+		},
+		{
+			name: "Redact hash action needs a key",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				RedactDetectors:    []string{"email"},
+				RedactAction:       "hash",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Part limits need to be positive",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				MaxPartBytes:       -1,
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 3,
+			},
+			fails: true,
+		},
+		{
+			name: "Window needs to be shorter than the default maximum",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 48,
+			},
+			fails: true,
+		},
+		{
+			name: "Window can be longer when the maximum is raised",
+			config: Config{
+				GroupName:          "/skpr/test/things",
+				StreamName:         "fpm",
+				BucketName:         "skpr-test",
+				BucketPrefix:       "/my/test/prefix",
+				TemporaryDirectory: "/tmp",
+				Start:              -time.Hour * 48,
+				MaxWindow:          time.Hour * 72,
+			},
+			fails: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -96,3 +548,221 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadValidConfig(t *testing.T) {
+	config, err := LoadValidConfig("testdata", Override{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/skpr/test/things", config.GroupName)
+
+	start := time.Now()
+
+	_, err = LoadValidConfig("testdata", Override{
+		GroupName:  "/skpr/test/other",
+		BucketName: "-invalid-",
+		StartTime:  &start,
+	})
+
+	var invalid *ValidationError
+	assert.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Problems, 2)
+}
+
+func TestCheckBucketName(t *testing.T) {
+	for _, name := range []string{"skpr-test", "skpr.test.123", "abc"} {
+		assert.NoError(t, checkBucketName(name), name)
+	}
+
+	for _, name := range []string{"ab", "-skpr", "skpr-", "Skpr", "skpr_test", "skpr..test", "192.168.5.4", "xn--skpr", "skpr-s3alias"} {
+		assert.Error(t, checkBucketName(name), name)
+	}
+}
+
+func TestLoadJobs(t *testing.T) {
+	maxLines, maxGap := 200, 2*time.Second
+	on, off := true, false
+
+	jobs, err := LoadJobs("testdata/jobs.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, []Job{
+		{
+			Name:         "app",
+			GroupName:    "/skpr/test/app",
+			StreamName:   "fpm-*",
+			StreamMatch:  "glob",
+			Start:        -time.Hour * 2,
+			End:          -time.Hour,
+			BucketName:   "skpr-archive",
+			BucketPrefix: "/archive",
+			Filter: Filter{
+				Exclude: []string{"GET /healthz"},
+				Fields:  []string{`level != "debug"`},
+			},
+			Multiline: Multiline{
+				Start:    `^\[`,
+				MaxLines: &maxLines,
+				MaxGap:   &maxGap,
+			},
+		},
+		{
+			GroupName:  "/skpr/test/things",
+			AllStreams: true,
+			Redact: Redact{
+				HashKey: "secret",
+				Rules: []RedactRule{
+					{Detector: "email", Action: "hash"},
+					{Name: "session", Pattern: `PHPSESSID=\w+`},
+				},
+			},
+		},
+	}, jobs)
+
+	jobs, err = LoadJobs("testdata/jobs.json")
+	assert.NoError(t, err)
+	assert.Equal(t, []Job{
+		{
+			Name:       "nginx",
+			GroupName:  "/skpr/test/things",
+			StreamName: "nginx",
+			Object: Object{
+				SSE:      "aws:kms",
+				KMSKeyID: "alias/sentinel",
+				TagBatch: &on,
+				Metadata: map[string]string{"source": "cloudwatch"},
+			},
+			Sinks: []Sink{
+				{
+					Type:         SinkS3,
+					BucketName:   "skpr-archive",
+					BucketPrefix: "/archive",
+					Object: Object{
+						StorageClass: "STANDARD_IA",
+						TagBatch:     &off,
+					},
+				},
+				{
+					Type:      SinkFile,
+					Name:      "local",
+					Directory: "/tmp/sentinel",
+				},
+			},
+		},
+	}, jobs)
+}
+
+func TestExportJobs(t *testing.T) {
+	config := Config{
+		GroupName:          "/skpr/test/things",
+		StreamName:         "fpm",
+		StreamMatch:        "exact",
+		Start:              -time.Hour,
+		BucketName:         "skpr-test",
+		BucketPrefix:       "/my/test/prefix",
+		TemporaryDirectory: "/tmp",
+	}
+
+	assert.Equal(t, []Job{
+		{
+			Name:         "/skpr/test/things",
+			GroupName:    "/skpr/test/things",
+			StreamName:   "fpm",
+			StreamMatch:  "exact",
+			Start:        -time.Hour,
+			BucketName:   "skpr-test",
+			BucketPrefix: "/my/test/prefix",
+		},
+	}, config.ExportJobs())
+	assert.Empty(t, config.Validate())
+
+	config.JobsFile = "jobs.yaml"
+	config.Jobs = []Job{
+		{
+			Name:         "app",
+			GroupName:    "/skpr/test/app",
+			StreamName:   "fpm-*",
+			StreamMatch:  "glob",
+			Start:        -time.Hour * 2,
+			End:          -time.Hour,
+			BucketName:   "skpr-archive",
+			BucketPrefix: "/archive",
+		},
+		{
+			GroupName:  "/skpr/test/things",
+			AllStreams: true,
+		},
+	}
+
+	assert.Equal(t, []Job{
+		{
+			Name:         "app",
+			GroupName:    "/skpr/test/app",
+			StreamName:   "fpm-*",
+			StreamMatch:  "glob",
+			Start:        -time.Hour * 2,
+			End:          -time.Hour,
+			BucketName:   "skpr-archive",
+			BucketPrefix: "/archive",
+		},
+		{
+			Name:         "/skpr/test/things",
+			GroupName:    "/skpr/test/things",
+			StreamMatch:  "exact",
+			AllStreams:   true,
+			Start:        -time.Hour,
+			BucketName:   "skpr-test",
+			BucketPrefix: "/my/test/prefix",
+		},
+	}, config.ExportJobs())
+	assert.Empty(t, config.Validate())
+
+	config.Jobs = append(config.Jobs, Job{Name: "app"})
+	assert.Len(t, config.Validate(), 3)
+
+	// Jobs with their own redact rules still fall back to the hash key.
+	config.RedactDetectors = []string{"email", "pan"}
+	config.RedactAction = "hash"
+	config.RedactHashKey = "secret"
+	config.Jobs = []Job{
+		{GroupName: "/skpr/test/things"},
+		{GroupName: "/skpr/test/app", Redact: Redact{Rules: []RedactRule{{Detector: "ipv4", Action: "hash"}}}},
+	}
+
+	jobs := config.ExportJobs()
+	assert.Equal(t, Redact{HashKey: "secret", Rules: []RedactRule{{Detector: "email", Action: "hash"}, {Detector: "pan", Action: "hash"}}}, jobs[0].Redact)
+	assert.Equal(t, Redact{HashKey: "secret", Rules: []RedactRule{{Detector: "ipv4", Action: "hash"}}}, jobs[1].Redact)
+
+	// Multiline limits which aren't set fall back to the flat config, and a job can lift them by setting zero.
+	unlimited, gap := 0, time.Second
+
+	config.MultilineMaxLines = 500
+	config.MultilineMaxGap = 5 * time.Second
+	config.Jobs = []Job{
+		{GroupName: "/skpr/test/things"},
+		{GroupName: "/skpr/test/app", Multiline: Multiline{Start: `^\d{4}-`, MaxLines: &unlimited, MaxGap: &gap}},
+	}
+
+	jobs = config.ExportJobs()
+	assert.Equal(t, 500, *jobs[0].Multiline.MaxLines)
+	assert.Equal(t, 5*time.Second, *jobs[0].Multiline.MaxGap)
+
+	multiline, err := jobs[0].Multiline.Compile()
+	assert.NoError(t, err)
+	assert.Nil(t, multiline)
+
+	multiline, err = jobs[1].Multiline.Compile()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, multiline.MaxLines)
+	assert.Equal(t, time.Second, multiline.MaxGap)
+
+	// Jobs which don't set manifest fall back to the flat config, but can turn it off.
+	off := false
+
+	config.Manifest = true
+	config.Jobs = []Job{
+		{GroupName: "/skpr/test/things"},
+		{GroupName: "/skpr/test/app", Manifest: &off},
+	}
+
+	jobs = config.ExportJobs()
+	assert.True(t, jobs[0].Manifests())
+	assert.False(t, jobs[1].Manifests())
+}
@@ -0,0 +1,24 @@
+package util
+
+import (
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/filter"
+)
+
+// Filter declares client-side rules which drop events by their message after they are read from CloudWatch Logs.
+type Filter struct {
+	// Include only exports the messages which match at least one regular expression.
+	Include []string `mapstructure:"include"`
+	// Exclude drops the messages which match any regular expression eg. "GET /healthz".
+	Exclude []string `mapstructure:"exclude"`
+	// Fields compares the fields of JSON messages eg. `level != "debug"`. Every rule must hold.
+	Fields []string `mapstructure:"fields"`
+}
+
+// Compile returns the filter used to package events. Returns nil when there are no rules.
+func (f Filter) Compile() (*filter.Filter, error) {
+	return filter.New(filter.Config{
+		Include: f.Include,
+		Exclude: f.Exclude,
+		Fields:  f.Fields,
+	})
+}
@@ -0,0 +1,230 @@
+package util
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/streams"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/key"
+)
+
+// Job declares a single export from a log group to a bucket.
+type Job struct {
+	Name        string        `mapstructure:"name"`
+	GroupName   string        `mapstructure:"group_name"`
+	StreamName  string        `mapstructure:"stream_name"`
+	StreamMatch string        `mapstructure:"stream_match"`
+	AllStreams  bool          `mapstructure:"all_streams"`
+	Start       time.Duration `mapstructure:"start"`
+	End         time.Duration `mapstructure:"end"`
+	Format      string        `mapstructure:"format"`
+	// FilterPattern only exports the events which match a CloudWatch Logs filter pattern. Optional.
+	FilterPattern string `mapstructure:"filter_pattern"`
+	// Multiline merges continuation lines into the event before them. Optional.
+	Multiline Multiline `mapstructure:"multiline"`
+	// Filter drops events by their message before they are packaged. Optional.
+	Filter Filter `mapstructure:"filter"`
+	// Redact removes sensitive values from messages before they are packaged. Optional.
+	Redact Redact `mapstructure:"redact"`
+	// Columns written by the "csv" format.
+	Columns []string `mapstructure:"columns"`
+	// KeyTemplate names the object of each batch. Defaults to key.DefaultTemplate.
+	KeyTemplate string `mapstructure:"key_template"`
+	// Manifest writes a manifest of the objects produced by each run to the S3 and file sinks. Jobs which don't set it
+	// fall back to the flat config.
+	Manifest *bool `mapstructure:"manifest"`
+	// ManifestKeyTemplate names manifests. Defaults to manifest.DefaultKeyTemplate.
+	ManifestKeyTemplate string `mapstructure:"manifest_key_template"`
+	// MaxPartEvents, MaxPartBytes and MaxPartCompressedBytes split packages into parts. Unlimited when zero.
+	MaxPartEvents          int    `mapstructure:"max_part_events"`
+	MaxPartBytes           int64  `mapstructure:"max_part_bytes"`
+	MaxPartCompressedBytes int64  `mapstructure:"max_part_compressed_bytes"`
+	BucketName             string `mapstructure:"bucket_name"`
+	BucketPrefix           string `mapstructure:"bucket_prefix"`
+	// QueueURL is notified of each upload to BucketName. Optional.
+	QueueURL string `mapstructure:"queue_url"`
+	// Sinks the job delivers to. Defaults to a single S3 sink using BucketName, BucketPrefix and QueueURL.
+	Sinks []Sink `mapstructure:"sinks"`
+	// Object properties of uploads. S3 sinks fall back to them for anything they don't set.
+	Object `mapstructure:",squash"`
+	// StartTime and EndTime request an absolute window instead of one relative to the scheduled time.
+	StartTime time.Time `mapstructure:"-"`
+	EndTime   time.Time `mapstructure:"-"`
+}
+
+// Absolute reports whether an absolute window was requested.
+func (j Job) Absolute() bool {
+	return !j.StartTime.IsZero() || !j.EndTime.IsZero()
+}
+
+// Window returns the window to export relative to the reference time, unless an absolute window was requested.
+func (j Job) Window(reference time.Time) (time.Time, time.Time) {
+	if j.Absolute() {
+		return j.StartTime, j.EndTime
+	}
+
+	return reference.Add(j.Start), reference.Add(j.End)
+}
+
+// Limits returns the limits at which packages are split into parts.
+func (j Job) Limits() events.Limits {
+	return events.Limits{
+		Events:          j.MaxPartEvents,
+		Bytes:           j.MaxPartBytes,
+		CompressedBytes: j.MaxPartCompressedBytes,
+	}
+}
+
+// Length returns the length of the window this job exports.
+func (j Job) Length() time.Duration {
+	if j.Absolute() {
+		return j.EndTime.Sub(j.StartTime)
+	}
+
+	return j.End - j.Start
+}
+
+// Destinations returns the sinks the job delivers to.
+func (j Job) Destinations() []Sink {
+	if len(j.Sinks) > 0 {
+		sinks := make([]Sink, len(j.Sinks))
+
+		for i, sink := range j.Sinks {
+			if sink.Type == SinkS3 {
+				sink.Object = sink.Object.withDefaults(j.Object)
+			}
+
+			sinks[i] = sink
+		}
+
+		return sinks
+	}
+
+	return []Sink{
+		{
+			Type:         SinkS3,
+			BucketName:   j.BucketName,
+			BucketPrefix: j.BucketPrefix,
+			QueueURL:     j.QueueURL,
+			Object:       j.Object,
+		},
+	}
+}
+
+// Manifests reports whether a manifest is written for each run.
+func (j Job) Manifests() bool {
+	return j.Manifest != nil && *j.Manifest
+}
+
+// DiscoverStreams reports whether streams need to be discovered instead of exporting a single named stream.
+func (j Job) DiscoverStreams() bool {
+	return j.AllStreams || (j.StreamMatch != "" && j.StreamMatch != streams.MatchExact)
+}
+
+// Validate validates the job.
+func (j Job) Validate() []string {
+	var errors []string
+
+	if j.GroupName == "" {
+		errors = append(errors, "group_name is a required field")
+	}
+
+	if j.StreamName == "" && !j.AllStreams {
+		errors = append(errors, "stream_name is a required field when all_streams is not set")
+	}
+
+	if _, err := streams.NewMatcher(j.StreamMatch, j.StreamName); err != nil {
+		errors = append(errors, fmt.Sprintf("stream_match is invalid: %s", err))
+	}
+
+	if j.Start.Milliseconds() >= j.End.Milliseconds() {
+		errors = append(errors, "start should be a duration before end")
+	}
+
+	if err := events.CheckFormat(j.Format); err != nil {
+		errors = append(errors, fmt.Sprintf("format is invalid: %s", err))
+	}
+
+	if err := checkFilterPattern(j.FilterPattern); err != nil {
+		errors = append(errors, fmt.Sprintf("filter_pattern is invalid: %s", err))
+	}
+
+	if _, err := j.Multiline.Compile(); err != nil {
+		errors = append(errors, fmt.Sprintf("multiline is invalid: %s", err))
+	}
+
+	if _, err := j.Filter.Compile(); err != nil {
+		errors = append(errors, fmt.Sprintf("filter is invalid: %s", err))
+	}
+
+	if _, err := j.Redact.Compile(); err != nil {
+		errors = append(errors, fmt.Sprintf("redact is invalid: %s", err))
+	}
+
+	if err := events.CheckColumns(j.Columns); err != nil {
+		errors = append(errors, fmt.Sprintf("columns is invalid: %s", err))
+	}
+
+	if err := key.Check(j.KeyTemplate); err != nil {
+		errors = append(errors, fmt.Sprintf("key_template is invalid: %s", err))
+	}
+
+	if err := key.Check(j.ManifestKeyTemplate); err != nil {
+		errors = append(errors, fmt.Sprintf("manifest_key_template is invalid: %s", err))
+	}
+
+	if j.MaxPartEvents < 0 || j.MaxPartBytes < 0 || j.MaxPartCompressedBytes < 0 {
+		errors = append(errors, "max_part_events, max_part_bytes and max_part_compressed_bytes should not be negative")
+	}
+
+	if j.Absolute() && !j.StartTime.Before(j.EndTime) {
+		errors = append(errors, "start_time should be before end_time")
+	}
+
+	names := make(map[string]bool)
+
+	for i, sink := range j.Destinations() {
+		for _, problem := range sink.Validate() {
+			if len(j.Sinks) > 0 {
+				problem = fmt.Sprintf("sinks[%d]: %s", i, problem)
+			}
+
+			errors = append(errors, problem)
+		}
+
+		if sink.Type == SinkAzure && (!events.Carries(j.Format, j.Columns, events.ColumnTimestamp) || !events.Carries(j.Format, j.Columns, events.ColumnMessage)) {
+			errors = append(errors, fmt.Sprintf("sinks[%d]: azure sinks need the %q and %q columns", i, events.ColumnTimestamp, events.ColumnMessage))
+		}
+
+		if sink.Name != "" && names[sink.Name] {
+			errors = append(errors, fmt.Sprintf("sinks[%d]: name must be unique", i))
+		}
+
+		names[sink.Name] = true
+	}
+
+	return errors
+}
+
+// LoadJobs reads a list of jobs from a YAML or JSON file.
+func LoadJobs(path string) ([]Job, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	var jobs []Job
+
+	err := v.ReadInConfig()
+	if err != nil {
+		return jobs, fmt.Errorf("failed to read jobs: %w", err)
+	}
+
+	err = v.UnmarshalKey("jobs", &jobs)
+	if err != nil {
+		return jobs, fmt.Errorf("failed to unmarshal jobs: %w", err)
+	}
+
+	return jobs, nil
+}
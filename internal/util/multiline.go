@@ -0,0 +1,54 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+)
+
+// Multiline declares how continuation lines eg. of stack traces are merged into the event before them.
+type Multiline struct {
+	// Start is a regular expression which matches the first line of each event. Disabled when empty.
+	Start string `mapstructure:"start"`
+	// MaxLines merged into a single event. Unlimited when zero. Jobs which don't set it fall back to the flat config.
+	MaxLines *int `mapstructure:"max_lines"`
+	// MaxGap between consecutive lines of the same event. Unlimited when zero. Jobs which don't set it fall back to
+	// the flat config.
+	MaxGap *time.Duration `mapstructure:"max_gap"`
+}
+
+// Compile returns how events are merged when they are packaged. Returns nil when disabled.
+func (m Multiline) Compile() (*events.Multiline, error) {
+	if m.Start == "" {
+		return nil, nil
+	}
+
+	start, err := regexp.Compile(m.Start)
+	if err != nil {
+		return nil, fmt.Errorf("start is invalid: %w", err)
+	}
+
+	multiline := &events.Multiline{
+		Start: start,
+	}
+
+	if m.MaxLines != nil {
+		multiline.MaxLines = *m.MaxLines
+	}
+
+	if m.MaxGap != nil {
+		multiline.MaxGap = *m.MaxGap
+	}
+
+	if multiline.MaxLines < 0 {
+		return nil, fmt.Errorf("max_lines should not be negative")
+	}
+
+	if multiline.MaxGap < 0 {
+		return nil, fmt.Errorf("max_gap should not be a negative duration")
+	}
+
+	return multiline, nil
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MaxObjectTags is the number of tags S3 allows on an object.
+const MaxObjectTags = 10
+
+// Characters allowed in object tags.
+var tagRegex = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// Object declares the properties of objects uploaded to S3. Empty fields are left to the defaults of the bucket.
+type Object struct {
+	// SSE is the server-side encryption of objects. One of "AES256", "aws:kms" or "aws:kms:dsse".
+	SSE string `mapstructure:"sse"`
+	// KMSKeyID encrypts objects when SSE uses KMS. Defaults to the AWS managed key.
+	KMSKeyID string `mapstructure:"kms_key_id"`
+	// StorageClass of objects eg. "STANDARD_IA".
+	StorageClass string `mapstructure:"storage_class"`
+	// TagBatch tags objects with their log group, log stream and event count. Falls back to the job, then the flat
+	// config, when not set.
+	TagBatch *bool             `mapstructure:"tag_batch"`
+	Tags     map[string]string `mapstructure:"tags"`
+	Metadata map[string]string `mapstructure:"metadata"`
+	// ContentType defaults to the media type of the format eg. "text/csv".
+	ContentType string `mapstructure:"content_type"`
+	// ContentEncoding defaults to "gzip".
+	ContentEncoding string `mapstructure:"content_encoding"`
+}
+
+// Returns the object with its empty fields taken from the defaults.
+func (o Object) withDefaults(defaults Object) Object {
+	o.SSE = override(defaults.SSE, o.SSE)
+	o.KMSKeyID = override(defaults.KMSKeyID, o.KMSKeyID)
+	o.StorageClass = override(defaults.StorageClass, o.StorageClass)
+	o.ContentType = override(defaults.ContentType, o.ContentType)
+	o.ContentEncoding = override(defaults.ContentEncoding, o.ContentEncoding)
+
+	if o.TagBatch == nil {
+		o.TagBatch = defaults.TagBatch
+	}
+
+	if o.Tags == nil {
+		o.Tags = defaults.Tags
+	}
+
+	if o.Metadata == nil {
+		o.Metadata = defaults.Metadata
+	}
+
+	return o
+}
+
+// TagsBatch reports whether objects are tagged with their log group, log stream and event count.
+func (o Object) TagsBatch() bool {
+	return o.TagBatch != nil && *o.TagBatch
+}
+
+// Reports whether any property was set.
+func (o Object) isSet() bool {
+	return o.SSE != "" || o.KMSKeyID != "" || o.StorageClass != "" || o.TagBatch != nil || len(o.Tags) > 0 ||
+		len(o.Metadata) > 0 || o.ContentType != "" || o.ContentEncoding != ""
+}
+
+// Validate validates the object properties.
+func (o Object) Validate() []string {
+	var errors []string
+
+	if err := checkSSE(o.SSE, o.KMSKeyID); err != nil {
+		errors = append(errors, fmt.Sprintf("sse is invalid: %s", err))
+	}
+
+	if err := checkStorageClass(o.StorageClass); err != nil {
+		errors = append(errors, fmt.Sprintf("storage_class is invalid: %s", err))
+	}
+
+	limit := MaxObjectTags
+	if o.TagsBatch() {
+		limit -= 3
+	}
+
+	if len(o.Tags) > limit {
+		errors = append(errors, fmt.Sprintf("tags is invalid: must not have more than %d tags", limit))
+	}
+
+	for k, v := range o.Tags {
+		if k == "" || len(k) > 128 || !tagRegex.MatchString(k) || len(v) > 256 || !tagRegex.MatchString(v) {
+			errors = append(errors, fmt.Sprintf("tags is invalid: %q must be a key of up to 128 and a value of up to 256 letters, numbers, spaces and _.:/=+-@", k))
+		}
+	}
+
+	return errors
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectValidate(t *testing.T) {
+	on := true
+
+	var tests = []struct {
+		name     string
+		object   Object
+		problems []string
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "KMS",
+			object: Object{
+				SSE:          "aws:kms",
+				KMSKeyID:     "alias/sentinel",
+				StorageClass: "STANDARD_IA",
+				TagBatch:     &on,
+				Tags:         map[string]string{"team": "platform"},
+			},
+		},
+		{
+			name: "Unknown encryption",
+			object: Object{
+				SSE: "rot13",
+			},
+			problems: []string{`sse is invalid: must be one of "AES256", "aws:kms" or "aws:kms:dsse"`},
+		},
+		{
+			name: "KMS key without KMS",
+			object: Object{
+				SSE:      "AES256",
+				KMSKeyID: "alias/sentinel",
+			},
+			problems: []string{"sse is invalid: must use KMS when a KMS key is set"},
+		},
+		{
+			name: "Unknown storage class",
+			object: Object{
+				StorageClass: "COLD",
+			},
+			problems: []string{`storage_class is invalid: unknown storage class "COLD"`},
+		},
+		{
+			name: "Too many tags",
+			object: Object{
+				TagBatch: &on,
+				Tags:     map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6", "g": "7", "h": "8"},
+			},
+			problems: []string{"tags is invalid: must not have more than 7 tags"},
+		},
+		{
+			name: "Invalid tag",
+			object: Object{
+				Tags: map[string]string{"team": "[platform]"},
+			},
+			problems: []string{`tags is invalid: "team" must be a key of up to 128 and a value of up to 256 letters, numbers, spaces and _.:/=+-@`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.problems, test.object.Validate())
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// Override is provided by an invocation payload to change the config for ad-hoc and backfill runs.
+// Fields which are not set keep their configured value.
+type Override struct {
+	// Job limits the run to a single job by name.
+	Job          string     `json:"job,omitempty"`
+	GroupName    string     `json:"group_name,omitempty"`
+	StreamName   string     `json:"stream_name,omitempty"`
+	StreamMatch  string     `json:"stream_match,omitempty"`
+	AllStreams   *bool      `json:"all_streams,omitempty"`
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	EndTime      *time.Time `json:"end_time,omitempty"`
+	BucketName   string     `json:"bucket_name,omitempty"`
+	BucketPrefix string     `json:"bucket_prefix,omitempty"`
+	// Backfill walks a historical range in fixed-size chunks.
+	Backfill *Backfill `json:"backfill,omitempty"`
+}
+
+// Backfill requests the export of the [From, To) range, one chunk at a time.
+type Backfill struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Chunk is the length of each window eg. "1h". Defaults to CLOUDWATCH_LOGS_SENTINEL_BACKFILL_CHUNK.
+	Chunk string `json:"chunk,omitempty"`
+}
+
+// Apply merges the override into the config and each of its jobs.
+func (o Override) Apply(c Config) (Config, error) {
+	// The flat job is named after the group it was configured with, not the group it is overridden to.
+	name := c.GroupName
+
+	c.GroupName = override(c.GroupName, o.GroupName)
+	c.StreamName = override(c.StreamName, o.StreamName)
+	c.StreamMatch = override(c.StreamMatch, o.StreamMatch)
+	c.BucketName = override(c.BucketName, o.BucketName)
+	c.BucketPrefix = override(c.BucketPrefix, o.BucketPrefix)
+
+	if o.AllStreams != nil {
+		c.AllStreams = *o.AllStreams
+	}
+
+	if o.StartTime != nil {
+		c.StartTime = o.StartTime.UTC()
+	}
+
+	if o.EndTime != nil {
+		c.EndTime = o.EndTime.UTC()
+	}
+
+	if o.Backfill != nil {
+		c.BackfillFrom = o.Backfill.From.UTC()
+		c.BackfillTo = o.Backfill.To.UTC()
+
+		if o.Backfill.Chunk != "" {
+			chunk, err := time.ParseDuration(o.Backfill.Chunk)
+			if err != nil {
+				return c, fmt.Errorf("failed to parse backfill chunk: %w", err)
+			}
+
+			c.BackfillChunk = chunk
+		}
+	}
+
+	if c.JobsFile == "" {
+		if o.Job != "" && o.Job != name {
+			return c, fmt.Errorf("job %q not found", o.Job)
+		}
+
+		return c, nil
+	}
+
+	var jobs []Job
+
+	for _, job := range c.Jobs {
+		if o.Job != "" && job.Name != o.Job && job.GroupName != o.Job {
+			continue
+		}
+
+		job.GroupName = override(job.GroupName, o.GroupName)
+		job.StreamName = override(job.StreamName, o.StreamName)
+		job.StreamMatch = override(job.StreamMatch, o.StreamMatch)
+		job.BucketName = override(job.BucketName, o.BucketName)
+		job.BucketPrefix = override(job.BucketPrefix, o.BucketPrefix)
+
+		if o.AllStreams != nil {
+			job.AllStreams = *o.AllStreams
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if len(jobs) == 0 && o.Job != "" {
+		return c, fmt.Errorf("job %q not found", o.Job)
+	}
+
+	c.Jobs = jobs
+
+	return c, nil
+}
+
+func override(value, override string) string {
+	if override != "" {
+		return override
+	}
+
+	return value
+}
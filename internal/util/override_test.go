@@ -0,0 +1,166 @@
+package util
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverrideApply(t *testing.T) {
+	var override Override
+
+	err := json.Unmarshal([]byte(`{
+		"stream_name": "nginx",
+		"start_time": "2026-10-01T00:00:00Z",
+		"end_time": "2026-10-01T16:00:00+10:00",
+		"bucket_prefix": "/incident"
+	}`), &override)
+	assert.NoError(t, err)
+
+	config := Config{
+		GroupName:          "/skpr/test/things",
+		StreamName:         "fpm",
+		Start:              -time.Hour,
+		BucketName:         "skpr-test",
+		BucketPrefix:       "/my/test/prefix",
+		TemporaryDirectory: "/tmp",
+	}
+
+	config, err = override.Apply(config)
+	assert.NoError(t, err)
+	assert.Empty(t, config.Validate())
+
+	jobs := config.ExportJobs()
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, "nginx", jobs[0].StreamName)
+	assert.Equal(t, "skpr-test", jobs[0].BucketName)
+	assert.Equal(t, "/incident", jobs[0].BucketPrefix)
+	assert.True(t, jobs[0].Absolute())
+
+	start, end := jobs[0].Window(time.Now())
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2026, 10, 1, 6, 0, 0, 0, time.UTC), end)
+
+	config.EndTime = config.StartTime.Add(-time.Hour)
+	assert.Len(t, config.Validate(), 1)
+}
+
+func TestOverrideApplyFlatJob(t *testing.T) {
+	config := Config{
+		GroupName:          "/skpr/test/things",
+		StreamName:         "fpm",
+		Start:              -time.Hour,
+		BucketName:         "skpr-test",
+		BucketPrefix:       "/my/test/prefix",
+		TemporaryDirectory: "/tmp",
+	}
+
+	var tests = []struct {
+		name     string
+		override Override
+		group    string
+		fails    bool
+	}{
+		{
+			name:     "Without a job",
+			override: Override{GroupName: "/skpr/test/other"},
+			group:    "/skpr/test/other",
+		},
+		{
+			name:     "Names the configured job",
+			override: Override{Job: "/skpr/test/things"},
+			group:    "/skpr/test/things",
+		},
+		{
+			name:     "Names the configured job and overrides its group",
+			override: Override{Job: "/skpr/test/things", GroupName: "/skpr/test/other"},
+			group:    "/skpr/test/other",
+		},
+		{
+			name:     "Names the overridden group",
+			override: Override{Job: "/skpr/test/other", GroupName: "/skpr/test/other"},
+			fails:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overridden, err := tt.override.Apply(config)
+			if tt.fails {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.group, overridden.GroupName)
+		})
+	}
+}
+
+func TestOverrideApplyJobs(t *testing.T) {
+	allStreams := true
+
+	config := Config{
+		Start:              -time.Hour,
+		BucketName:         "skpr-test",
+		BucketPrefix:       "/my/test/prefix",
+		TemporaryDirectory: "/tmp",
+		JobsFile:           "jobs.yaml",
+		Jobs: []Job{
+			{Name: "app", GroupName: "/skpr/test/app", StreamName: "fpm"},
+			{Name: "cron", GroupName: "/skpr/test/cron", StreamName: "cron"},
+		},
+	}
+
+	overridden, err := Override{Job: "cron", AllStreams: &allStreams}.Apply(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []Job{
+		{Name: "cron", GroupName: "/skpr/test/cron", StreamName: "cron", AllStreams: true},
+	}, overridden.Jobs)
+
+	_, err = Override{Job: "missing"}.Apply(config)
+	assert.Error(t, err)
+
+	overridden, err = Override{}.Apply(config)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Jobs, overridden.Jobs)
+}
+
+func TestOverrideApplyBackfill(t *testing.T) {
+	var override Override
+
+	err := json.Unmarshal([]byte(`{
+		"backfill": {
+			"from": "2026-07-01T00:00:00Z",
+			"to": "2026-10-01T00:00:00Z",
+			"chunk": "30m"
+		}
+	}`), &override)
+	assert.NoError(t, err)
+
+	config := Config{
+		GroupName:          "/skpr/test/things",
+		StreamName:         "fpm",
+		Start:              -time.Hour,
+		BucketName:         "skpr-test",
+		BucketPrefix:       "/my/test/prefix",
+		TemporaryDirectory: "/tmp",
+		BackfillChunk:      time.Hour,
+	}
+
+	config, err = override.Apply(config)
+	assert.NoError(t, err)
+	assert.Empty(t, config.Validate())
+	assert.True(t, config.Backfilling())
+	assert.Equal(t, time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), config.BackfillFrom)
+	assert.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), config.BackfillTo)
+	assert.Equal(t, time.Minute*30, config.BackfillChunk)
+
+	config.BackfillChunk = time.Hour * 48
+	assert.Len(t, config.Validate(), 1)
+
+	_, err = Override{Backfill: &Backfill{Chunk: "fortnight"}}.Apply(config)
+	assert.Error(t, err)
+}
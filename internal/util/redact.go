@@ -0,0 +1,42 @@
+package util
+
+import (
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/redact"
+)
+
+// Redact declares the rules which remove sensitive values from messages before they are packaged.
+type Redact struct {
+	// HashKey used to HMAC matches of rules with the "hash" action.
+	HashKey string       `mapstructure:"hash_key"`
+	Rules   []RedactRule `mapstructure:"rules"`
+}
+
+// RedactRule redacts the matches of a built-in detector or a regular expression.
+type RedactRule struct {
+	// Name the hits of the rule are reported under. Defaults to the detector. Required for a pattern.
+	Name string `mapstructure:"name"`
+	// Detector is one of "email", "ipv4", "ipv6", "jwt", "aws_access_key" or "pan".
+	Detector string `mapstructure:"detector"`
+	// Pattern is a regular expression.
+	Pattern string `mapstructure:"pattern"`
+	// Action is one of "mask", "hash" or "drop". Defaults to "mask".
+	Action string `mapstructure:"action"`
+}
+
+// Compile returns the redactor used to package events. Returns nil when there are no rules.
+func (r Redact) Compile() (*redact.Redactor, error) {
+	config := redact.Config{
+		HashKey: r.HashKey,
+	}
+
+	for _, rule := range r.Rules {
+		config.Rules = append(config.Rules, redact.Rule{
+			Name:     rule.Name,
+			Detector: rule.Detector,
+			Pattern:  rule.Pattern,
+			Action:   rule.Action,
+		})
+	}
+
+	return redact.New(config)
+}
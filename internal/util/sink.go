@@ -0,0 +1,135 @@
+package util
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	// SinkS3 uploads batches to an S3 bucket.
+	SinkS3 = "s3"
+	// SinkFile writes batches to the local filesystem.
+	SinkFile = "file"
+	// SinkAzure posts batches to the Azure Monitor Logs Ingestion API.
+	SinkAzure = "azure"
+)
+
+// EnvAzureClientSecret is used when client_secret is not set on an azure sink.
+const EnvAzureClientSecret = "AZURE_CLIENT_SECRET"
+
+// Sink declares a destination a job delivers its batches to.
+type Sink struct {
+	// Type of sink. One of "s3", "file" or "azure".
+	Type string `mapstructure:"type"`
+	// Name identifies the sink in logs and results. Defaults to a URL built from the destination.
+	Name         string `mapstructure:"name"`
+	BucketName   string `mapstructure:"bucket_name"`
+	BucketPrefix string `mapstructure:"bucket_prefix"`
+	Directory    string `mapstructure:"directory"`
+	// QueueURL is sent an S3 event notification after each batch is uploaded. Only supported by S3 sinks.
+	QueueURL string `mapstructure:"queue_url"`
+	// Endpoint is the logs ingestion endpoint of an Azure Data Collection Endpoint.
+	Endpoint string `mapstructure:"endpoint"`
+	// RuleID is the immutable ID of an Azure Data Collection Rule.
+	RuleID string `mapstructure:"rule_id"`
+	// StreamName declared by the Data Collection Rule eg. "Custom-CloudWatchLogs".
+	StreamName   string `mapstructure:"stream_name"`
+	TenantID     string `mapstructure:"tenant_id"`
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	// AuthorityHost issues tokens. Defaults to the Azure public cloud.
+	AuthorityHost string `mapstructure:"authority_host"`
+	// Object properties of uploads. Only supported by S3 sinks.
+	Object `mapstructure:",squash"`
+}
+
+// Secret returns the client secret of an azure sink.
+func (s Sink) Secret() string {
+	if s.ClientSecret != "" {
+		return s.ClientSecret
+	}
+
+	return os.Getenv(EnvAzureClientSecret)
+}
+
+// Validate validates the sink.
+func (s Sink) Validate() []string {
+	var errors []string
+
+	switch s.Type {
+	case SinkS3:
+		if s.BucketName == "" {
+			errors = append(errors, "bucket_name is a required field")
+		} else if err := checkBucketName(s.BucketName); err != nil {
+			errors = append(errors, fmt.Sprintf("bucket_name is invalid: %s", err))
+		}
+
+		if s.BucketPrefix == "" {
+			errors = append(errors, "bucket_prefix is a required field")
+		}
+
+		if s.QueueURL != "" {
+			if err := checkURL(s.QueueURL); err != nil {
+				errors = append(errors, fmt.Sprintf("queue_url is invalid: %s", err))
+			}
+		}
+
+		errors = append(errors, s.Object.Validate()...)
+	case SinkFile:
+		if s.Directory == "" {
+			errors = append(errors, "directory is a required field")
+		}
+
+		if s.QueueURL != "" {
+			errors = append(errors, "queue_url is only supported by s3 sinks")
+		}
+
+		if s.Object.isSet() {
+			errors = append(errors, "object properties are only supported by s3 sinks")
+		}
+	case SinkAzure:
+		if s.Endpoint == "" {
+			errors = append(errors, "endpoint is a required field")
+		} else if err := checkURL(s.Endpoint); err != nil {
+			errors = append(errors, fmt.Sprintf("endpoint is invalid: %s", err))
+		}
+
+		if s.AuthorityHost != "" {
+			if err := checkURL(s.AuthorityHost); err != nil {
+				errors = append(errors, fmt.Sprintf("authority_host is invalid: %s", err))
+			}
+		}
+
+		if s.RuleID == "" {
+			errors = append(errors, "rule_id is a required field")
+		}
+
+		if s.StreamName == "" {
+			errors = append(errors, "stream_name is a required field")
+		}
+
+		if s.TenantID == "" {
+			errors = append(errors, "tenant_id is a required field")
+		}
+
+		if s.ClientID == "" {
+			errors = append(errors, "client_id is a required field")
+		}
+
+		if s.Secret() == "" {
+			errors = append(errors, fmt.Sprintf("client_secret is a required field unless %s is set", EnvAzureClientSecret))
+		}
+
+		if s.QueueURL != "" {
+			errors = append(errors, "queue_url is only supported by s3 sinks")
+		}
+
+		if s.Object.isSet() {
+			errors = append(errors, "object properties are only supported by s3 sinks")
+		}
+	default:
+		errors = append(errors, fmt.Sprintf("type must be one of %q, %q or %q", SinkS3, SinkFile, SinkAzure))
+	}
+
+	return errors
+}
@@ -0,0 +1,156 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobDestinations(t *testing.T) {
+	job := Job{
+		BucketName:   "skpr-test",
+		BucketPrefix: "/my/test/prefix",
+	}
+
+	assert.Equal(t, []Sink{
+		{
+			Type:         SinkS3,
+			BucketName:   "skpr-test",
+			BucketPrefix: "/my/test/prefix",
+		},
+	}, job.Destinations())
+
+	job.Sinks = []Sink{
+		{
+			Type:      SinkFile,
+			Directory: "/tmp/sentinel",
+		},
+	}
+
+	assert.Equal(t, job.Sinks, job.Destinations())
+
+	// S3 sinks fall back to the object properties of the job for anything they don't set.
+	on, off := true, false
+
+	job.Object = Object{
+		SSE:      "aws:kms",
+		KMSKeyID: "alias/sentinel",
+		TagBatch: &on,
+	}
+	job.Sinks = []Sink{
+		{
+			Type:         SinkS3,
+			BucketName:   "skpr-archive",
+			BucketPrefix: "/archive",
+			Object: Object{
+				StorageClass: "STANDARD_IA",
+				TagBatch:     &off,
+			},
+		},
+		{
+			Type:      SinkFile,
+			Directory: "/tmp/sentinel",
+		},
+	}
+
+	assert.Equal(t, []Sink{
+		{
+			Type:         SinkS3,
+			BucketName:   "skpr-archive",
+			BucketPrefix: "/archive",
+			Object: Object{
+				SSE:          "aws:kms",
+				KMSKeyID:     "alias/sentinel",
+				StorageClass: "STANDARD_IA",
+				TagBatch:     &off,
+			},
+		},
+		{
+			Type:      SinkFile,
+			Directory: "/tmp/sentinel",
+		},
+	}, job.Destinations())
+}
+
+func TestJobValidateSinks(t *testing.T) {
+	job := Job{
+		GroupName:  "/skpr/test/things",
+		StreamName: "fpm",
+		Start:      -time.Hour,
+		Sinks: []Sink{
+			{
+				Type:         SinkS3,
+				Name:         "archive",
+				BucketName:   "skpr-archive",
+				BucketPrefix: "/archive",
+			},
+			{
+				Type:      SinkFile,
+				Name:      "local",
+				Directory: "/tmp/sentinel",
+			},
+		},
+	}
+
+	assert.Empty(t, job.Validate())
+
+	job.Sinks = append(job.Sinks,
+		Sink{Type: "ftp"},
+		Sink{Type: SinkFile, Name: "local"},
+		Sink{Type: SinkFile, Directory: "/tmp/sentinel", QueueURL: "https://sqs.ap-southeast-2.amazonaws.com/123456789012/sentinel"},
+		Sink{Type: SinkFile, Directory: "/tmp/sentinel", Object: Object{StorageClass: "GLACIER"}},
+	)
+
+	assert.Equal(t, []string{
+		`sinks[2]: type must be one of "s3", "file" or "azure"`,
+		"sinks[3]: directory is a required field",
+		"sinks[3]: name must be unique",
+		"sinks[4]: queue_url is only supported by s3 sinks",
+		"sinks[5]: object properties are only supported by s3 sinks",
+	}, job.Validate())
+}
+
+func TestSinkValidateAzure(t *testing.T) {
+	t.Setenv(EnvAzureClientSecret, "")
+
+	sink := Sink{
+		Type:       SinkAzure,
+		Endpoint:   "https://sentinel-abcd.australiaeast-1.ingest.monitor.azure.com",
+		RuleID:     "dcr-00000000000000000000000000000000",
+		StreamName: "Custom-CloudWatchLogs",
+		TenantID:   "00000000-0000-0000-0000-000000000000",
+		ClientID:   "00000000-0000-0000-0000-000000000000",
+	}
+
+	assert.Equal(t, []string{
+		"client_secret is a required field unless AZURE_CLIENT_SECRET is set",
+	}, sink.Validate())
+
+	t.Setenv(EnvAzureClientSecret, "secret")
+
+	assert.Empty(t, sink.Validate())
+
+	// Records are built from the timestamp and message of each event.
+	job := Job{
+		GroupName:  "/skpr/test/things",
+		StreamName: "fpm",
+		Start:      -time.Hour,
+		Columns:    []string{"group", "stream", "event_id"},
+		Sinks:      []Sink{sink},
+	}
+
+	assert.Equal(t, []string{`sinks[0]: azure sinks need the "timestamp" and "message" columns`}, job.Validate())
+
+	job.Format = "jsonl"
+
+	assert.Empty(t, job.Validate())
+
+	assert.Equal(t, []string{
+		"endpoint is invalid: must be an absolute URL",
+		"rule_id is a required field",
+		"stream_name is a required field",
+		"tenant_id is a required field",
+		"client_id is a required field",
+	}, Sink{Type: SinkAzure, Endpoint: "sentinel"}.Validate())
+}
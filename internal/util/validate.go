@@ -0,0 +1,107 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ValidationError lists every problem found with the config.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns all problems as a single message.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(e.Problems, "; "))
+}
+
+var (
+	// https://docs.aws.amazon.com/AmazonS3/latest/userguide/bucketnamingrules.html
+	bucketNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	ipAddressRegex  = regexp.MustCompile(`^\d+\.\d+\.\d+\.\d+$`)
+)
+
+// Checks the bucket name follows the S3 naming rules.
+func checkBucketName(name string) error {
+	if !bucketNameRegex.MatchString(name) {
+		return errors.New("must be 3-63 characters of lowercase letters, numbers, dots and hyphens, beginning and ending with a letter or number")
+	}
+
+	if strings.Contains(name, "..") {
+		return errors.New("must not contain two adjacent periods")
+	}
+
+	if ipAddressRegex.MatchString(name) {
+		return errors.New("must not be formatted as an IP address")
+	}
+
+	if strings.HasPrefix(name, "xn--") || strings.HasSuffix(name, "-s3alias") || strings.HasSuffix(name, "--ol-s3") {
+		return errors.New("must not use a reserved prefix or suffix")
+	}
+
+	return nil
+}
+
+// Checks that files can be created in the directory.
+func checkWritable(directory string) error {
+	file, err := os.CreateTemp(directory, ".write-check-*")
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(file.Close(), os.Remove(file.Name()))
+}
+
+// Checks the server-side encryption is supported by S3 and that a KMS key is only set when it is used.
+func checkSSE(sse, kmsKeyID string) error {
+	switch sse {
+	case "", string(types.ServerSideEncryptionAes256), string(types.ServerSideEncryptionAwsKms), string(types.ServerSideEncryptionAwsKmsDsse):
+	default:
+		return fmt.Errorf("must be one of %q, %q or %q", types.ServerSideEncryptionAes256, types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse)
+	}
+
+	if kmsKeyID != "" && !strings.HasPrefix(sse, string(types.ServerSideEncryptionAwsKms)) {
+		return errors.New("must use KMS when a KMS key is set")
+	}
+
+	return nil
+}
+
+// Checks the storage class is supported by S3.
+func checkStorageClass(class string) error {
+	if class == "" || slices.Contains(types.StorageClass("").Values(), types.StorageClass(class)) {
+		return nil
+	}
+
+	return fmt.Errorf("unknown storage class %q", class)
+}
+
+// Checks a filter pattern is within the limits of CloudWatch Logs. The syntax is checked by the service.
+func checkFilterPattern(pattern string) error {
+	if len(pattern) > 1024 {
+		return errors.New("must not be longer than 1024 characters")
+	}
+
+	return nil
+}
+
+// Checks the value is an absolute URL.
+func checkURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return errors.New("must be an absolute URL")
+	}
+
+	return nil
+}
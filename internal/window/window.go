@@ -0,0 +1,51 @@
+package window
+
+import (
+	"time"
+)
+
+// Reference returns the time which export windows are calculated from. The scheduled time of the
+// triggering event is preferred over wall-clock time so that retried and delayed invocations export
+// the same window. When align is set, the time is truncated to that boundary eg. the top of the hour.
+func Reference(scheduled, now time.Time, align time.Duration) time.Time {
+	reference := scheduled
+
+	if reference.IsZero() {
+		reference = now
+	}
+
+	reference = reference.UTC()
+
+	if align > 0 {
+		reference = reference.Truncate(align)
+	}
+
+	return reference
+}
+
+// Window is a [Start, End) range of time to export.
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Split divides [from, to) into consecutive windows no longer than chunk. The final window is shortened to end at to.
+func Split(from, to time.Time, chunk time.Duration) []Window {
+	var windows []Window
+
+	if chunk <= 0 {
+		return windows
+	}
+
+	for start := from; start.Before(to); start = start.Add(chunk) {
+		end := start.Add(chunk)
+
+		if end.After(to) {
+			end = to
+		}
+
+		windows = append(windows, Window{Start: start, End: end})
+	}
+
+	return windows
+}
@@ -0,0 +1,66 @@
+package window
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReference(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 42, 17, 0, time.UTC)
+	scheduled := time.Date(2026, 10, 16, 10, 0, 3, 0, time.UTC)
+
+	var tests = []struct {
+		name      string
+		scheduled time.Time
+		align     time.Duration
+		want      time.Time
+	}{
+		{
+			name: "Falls back to wall-clock time",
+			want: now,
+		},
+		{
+			name:      "Uses the scheduled time",
+			scheduled: scheduled,
+			want:      scheduled,
+		},
+		{
+			name:      "Aligns to the top of the hour",
+			scheduled: scheduled,
+			align:     time.Hour,
+			want:      time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "Aligns wall-clock time to five minutes",
+			align: time.Minute * 5,
+			want:  time.Date(2026, 10, 16, 10, 40, 0, 0, time.UTC),
+		},
+		{
+			name:      "Converts to UTC",
+			scheduled: time.Date(2026, 10, 16, 21, 30, 0, 0, time.FixedZone("AEDT", 11*60*60)),
+			align:     time.Hour,
+			want:      time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Reference(tt.scheduled, now, tt.align))
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	from := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, []Window{
+		{Start: from, End: from.Add(time.Hour)},
+		{Start: from.Add(time.Hour), End: from.Add(time.Hour * 2)},
+		{Start: from.Add(time.Hour * 2), End: from.Add(time.Hour*2 + time.Minute*30)},
+	}, Split(from, from.Add(time.Hour*2+time.Minute*30), time.Hour))
+
+	assert.Empty(t, Split(from, from, time.Hour))
+	assert.Empty(t, Split(from, from.Add(time.Hour), 0))
+}
@@ -2,130 +2,254 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	lambdaevents "github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	s3manager "github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 
-	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/cloudwatch/events"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/checkpoint"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/export"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/throttle"
 	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/util"
+	"github.com/skpr/cloudwatch-logs-sentinel-lambda/internal/window"
 )
 
 const (
-	// LogKeyCloudWatchLogsGroupName is the name of the log stream.
-	LogKeyCloudWatchLogsGroupName = "cloudwatch_logs_group_name"
-	// LogKeyCloudWatchLogsStreamName is the name of the log stream.
-	LogKeyCloudWatchLogsStreamName = "cloudwatch_logs_stream_name"
-	// LogKeyCloudWatchLogsStreamStartTime is the start time of the log stream.
-	LogKeyCloudWatchLogsStreamStartTime = "cloudwatch_logs_stream_start_time"
-	// LogKeyCloudWatchLogsStreamEndTime is the finish time of the log stream.
-	LogKeyCloudWatchLogsStreamEndTime = "cloudwatch_logs_stream_end_time"
-	// LogKeyCloudWatchLogsStreamLogCount is the number of log events in the stream.
-	LogKeyCloudWatchLogsStreamLogCount = "cloudwatch_logs_stream_log_count"
-	// LogKeyTemporaryFilePath is the path to the temporary file.
-	LogKeyTemporaryFilePath = "temporary_file_path"
-	// LogKeyS3BucketName is the name of the S3 bucket.
-	LogKeyS3BucketName = "s3_bucket_name"
-	// LogKeyS3BucketKey is the key of the S3 object.
-	LogKeyS3BucketKey = "s3_bucket_key"
+	// LogKeyJobCount is the number of jobs being executed.
+	LogKeyJobCount = "job_count"
+	// LogKeyJobFailedCount is the number of jobs which failed.
+	LogKeyJobFailedCount = "job_failed_count"
+	// LogKeyEventID is the ID of the event which triggered the function.
+	LogKeyEventID = "event_id"
+	// LogKeyEventTime is the scheduled time of the event which triggered the function.
+	LogKeyEventTime = "event_time"
+	// LogKeyConfigProblems is the list of problems found with the config.
+	LogKeyConfigProblems = "config_problems"
 )
 
-func handler(ctx context.Context) error {
+// Payload used to invoke this function. EventBridge scheduled events are accepted as is, while
+// ad-hoc and backfill runs can override the config eg. to export a specific historical window.
+type Payload struct {
+	lambdaevents.CloudWatchEvent
+	util.Override
+}
+
+// Summary of the jobs executed by this function.
+type Summary struct {
+	Jobs   []export.Result `json:"jobs"`
+	Failed int             `json:"failed"`
+	// Retries of AWS requests made by all jobs.
+	Retries int `json:"retries"`
+	// Redactions counts the hits of each redaction rule across all jobs.
+	Redactions map[string]int `json:"redactions,omitempty"`
+}
+
+func handler(ctx context.Context, event Payload) (Summary, error) {
+	var summary Summary
+
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 	logger.LogAttrs(ctx, slog.LevelInfo, "Starting function")
 
-	config, err := util.LoadConfig(".")
+	config, err := util.LoadValidConfig(".", event.Override)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		var invalid *util.ValidationError
+		if errors.As(err, &invalid) {
+			logger.LogAttrs(ctx, slog.LevelError, "Invalid config",
+				slog.Any(LogKeyConfigProblems, invalid.Problems))
+		}
+
+		return summary, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	start := time.Now().Add(config.Start).UTC()
-	end := time.Now().Add(config.End).UTC()
+	jobs := config.ExportJobs()
 
 	logger.LogAttrs(ctx, slog.LevelInfo, "Executing function",
-		slog.String(LogKeyCloudWatchLogsGroupName, config.GroupName),
-		slog.String(LogKeyCloudWatchLogsStreamStartTime, start.String()),
-		slog.String(LogKeyCloudWatchLogsStreamEndTime, end.String()),
-		slog.String(LogKeyS3BucketName, config.BucketName))
+		slog.Int(LogKeyJobCount, len(jobs)),
+		slog.String(LogKeyEventID, event.ID),
+		slog.String(LogKeyEventTime, event.Time.String()))
 
-	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRetryer(func() aws.Retryer {
+		return throttle.NewRetryer(config.Throttle())
+	}))
 	if err != nil {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
 
-	// Client used to download and package CloudWatch Logs.
-	svc := cloudwatchlogs.NewFromConfig(cfg)
+	s3Client := s3.NewFromConfig(cfg)
 
-	// Client for pushing package to S3.
-	uploader := s3manager.NewUploader(s3.NewFromConfig(cfg))
+	// Shared by every job so that they stay within the CloudWatch Logs quotas together.
+	limiter := throttle.NewLimiter(config.Throttle())
 
-	// This is used to create a unique upload file name.
-	now := time.Now().UTC().String()
+	clients := export.Clients{
+		CloudWatchLogs: cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) {
+			if limiter != nil {
+				o.APIOptions = append(o.APIOptions, limiter.AddTo)
+			}
+		}),
+		Uploader: s3manager.NewUploader(s3Client),
+		SQS: sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+			if config.SQSEndpoint != "" {
+				o.BaseEndpoint = aws.String(config.SQSEndpoint)
+			}
+		}),
+		Account: account(ctx),
+		Region:  cfg.Region,
+	}
 
-	logger.LogAttrs(ctx, slog.LevelInfo, "Packaging log events",
-		slog.String(LogKeyCloudWatchLogsGroupName, config.GroupName),
-		slog.String(LogKeyCloudWatchLogsStreamName, config.StreamName))
+	var checkpoints checkpoint.Store
 
-	output, hasEvents, err := events.Package(ctx, svc, events.PackageInput{
-		GroupName:  config.GroupName,
-		StreamName: config.StreamName,
-		StartTime:  start.UnixMilli(),
-		EndTime:    end.UnixMilli(),
-		Directory:  config.TemporaryDirectory,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to push log events, %w", err)
+	switch config.CheckpointStore {
+	case util.CheckpointStoreS3:
+		checkpoints = checkpoint.NewS3Store(s3Client, config.CheckpointBucketName, config.CheckpointPrefix)
+	case util.CheckpointStoreFile:
+		checkpoints = checkpoint.NewFileStore(config.CheckpointDirectory)
 	}
 
-	if !hasEvents {
-		logger.LogAttrs(ctx, slog.LevelInfo, "Stream does not have events. Skipping.",
-			slog.String(LogKeyCloudWatchLogsGroupName, config.GroupName),
-			slog.String(LogKeyCloudWatchLogsStreamName, config.StreamName),
-			slog.String(LogKeyTemporaryFilePath, output.FilePath),
-			slog.Int(LogKeyCloudWatchLogsStreamLogCount, output.Count))
-		return nil
+	// Windows are calculated from the scheduled time so that retries export the same window and upload to the same key.
+	now := window.Reference(event.Time, time.Now(), config.Align)
+
+	var (
+		errs    = make([]error, len(jobs))
+		wg      sync.WaitGroup
+		running = make(chan struct{}, max(config.Concurrency, 1))
+	)
+
+	summary.Jobs = make([]export.Result, len(jobs))
+
+	for i, job := range jobs {
+		wg.Add(1)
+		running <- struct{}{}
+
+		go func(i int, job util.Job) {
+			defer func() {
+				<-running
+				wg.Done()
+			}()
+
+			result, err := runJob(ctx, logger, clients, config, job, now, event.ID, checkpoints)
+			if err != nil {
+				result.Error = err.Error()
+
+				logger.LogAttrs(ctx, slog.LevelError, "Job failed",
+					slog.String(export.LogKeyJobName, job.Name),
+					slog.String(export.LogKeyCloudWatchLogsGroupName, job.GroupName),
+					slog.String(export.LogKeyError, err.Error()))
+
+				errs[i] = fmt.Errorf("job %q: %w", job.Name, err)
+			} else {
+				logger.LogAttrs(ctx, slog.LevelInfo, "Job succeeded",
+					slog.String(export.LogKeyJobName, job.Name),
+					slog.String(export.LogKeyCloudWatchLogsGroupName, job.GroupName),
+					slog.Int(export.LogKeyCloudWatchLogsStreamCount, result.Streams),
+					slog.Int(export.LogKeyCloudWatchLogsStreamLogCount, result.Count),
+					slog.Int(export.LogKeyCloudWatchLogsStreamDroppedCount, result.Dropped),
+					slog.Int(export.LogKeyRetryCount, result.Retries))
+			}
+
+			// Results keep the order of the jobs.
+			summary.Jobs[i] = result
+		}(i, job)
 	}
 
-	logger.LogAttrs(ctx, slog.LevelInfo, "Successfully packaged log events to filesystem",
-		slog.String(LogKeyCloudWatchLogsGroupName, config.GroupName),
-		slog.String(LogKeyCloudWatchLogsStreamName, config.StreamName),
-		slog.String(LogKeyTemporaryFilePath, output.FilePath),
-		slog.Int(LogKeyCloudWatchLogsStreamLogCount, output.Count))
+	wg.Wait()
 
-	file, err := os.Open(output.FilePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file %q, %w", output.FilePath, err)
+	for i, result := range summary.Jobs {
+		summary.Retries += result.Retries
+
+		for name, count := range result.Redactions {
+			if summary.Redactions == nil {
+				summary.Redactions = make(map[string]int)
+			}
+
+			summary.Redactions[name] += count
+		}
+
+		if errs[i] != nil {
+			summary.Failed++
+		}
 	}
 
-	key := fmt.Sprintf("%s/%s/%s.gz", config.BucketPrefix, config.StreamName, now)
+	logger.LogAttrs(ctx, slog.LevelInfo, "Finished function",
+		slog.Int(LogKeyJobCount, len(jobs)),
+		slog.Int(LogKeyJobFailedCount, summary.Failed),
+		slog.Int(export.LogKeyRetryCount, summary.Retries),
+		slog.Any(export.LogKeyRedactions, summary.Redactions))
 
-	_, err = uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(config.BucketName),
-		Key:    aws.String(key),
-		Body:   file,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to upload file %q, %w", output.FilePath, err)
+	return summary, errors.Join(errs...)
+}
+
+// Runs a single job as either a backfill or a regular export.
+func runJob(ctx context.Context, logger *slog.Logger, clients export.Clients, config util.Config, job util.Job, now time.Time, runID string, checkpoints checkpoint.Store) (export.Result, error) {
+	var stopBefore time.Time
+
+	// Leave enough time to upload what has been packaged and record where we stopped.
+	if deadline, ok := ctx.Deadline(); ok {
+		stopBefore = deadline.Add(-config.SafetyMargin)
+	}
+
+	if config.Backfilling() {
+		return export.Backfill(ctx, logger, clients, export.BackfillParams{
+			Job:                job,
+			From:               config.BackfillFrom,
+			To:                 config.BackfillTo,
+			Chunk:              config.BackfillChunk,
+			TemporaryDirectory: config.TemporaryDirectory,
+			Progress:           checkpoints,
+			StopBefore:         stopBefore,
+			Streaming:          config.Streaming,
+			RunID:              runID,
+		})
+	}
+
+	params := export.Params{
+		Job:                job,
+		TemporaryDirectory: config.TemporaryDirectory,
+		RunID:              runID,
+		Checkpoints:        checkpoints,
+		Lookback:           config.Lookback(),
+		StopBefore:         stopBefore,
+		Streaming:          config.Streaming,
 	}
 
-	logger.LogAttrs(ctx, slog.LevelInfo, "Finished pushing log events to S3 bucket",
-		slog.String(LogKeyCloudWatchLogsGroupName, config.GroupName),
-		slog.String(LogKeyCloudWatchLogsStreamName, config.StreamName),
-		slog.Int(LogKeyCloudWatchLogsStreamLogCount, output.Count),
-		slog.String(LogKeyTemporaryFilePath, output.FilePath),
-		slog.String(LogKeyS3BucketName, config.BucketName),
-		slog.String(LogKeyS3BucketKey, key))
+	params.Start, params.End = job.Window(now)
+
+	// Ad-hoc runs of a historical window must not move the checkpoints of scheduled runs.
+	if job.Absolute() {
+		params.Checkpoints = nil
+	}
+
+	return export.Run(ctx, logger, clients, params)
+}
+
+// Returns the account the function runs in, taken from the ARN it was invoked with.
+func account(ctx context.Context) string {
+	lc, ok := lambdacontext.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	// arn:aws:lambda:region:account:function:name
+	parts := strings.Split(lc.InvokedFunctionArn, ":")
+	if len(parts) < 5 {
+		return ""
+	}
 
-	return nil
+	return parts[4]
 }
 
 func main() {